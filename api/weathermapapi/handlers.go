@@ -0,0 +1,36 @@
+package weathermapapi
+
+import (
+	"encoding/json"
+	"led-map/supervisor"
+	"led-map/timeline"
+	"net/http"
+)
+
+//TimelineHandler serves the timeline the map is showing as JSON. Current is called on every request.
+func TimelineHandler(current func() timeline.Timeline) http.HandlerFunc {
+	return jsonHandler(func() interface{} {
+		return current()
+	})
+}
+
+//LedStatsHandler serves how the map's LEDs have been doing, such as how many renders failed, as JSON.
+//Current is called on every request.
+func LedStatsHandler(current func() supervisor.Stats) http.HandlerFunc {
+	return jsonHandler(func() interface{} {
+		return current()
+	})
+}
+
+//jsonHandler serves the value returned by current as JSON, in answer to GET requests.
+func jsonHandler(current func() interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(current())
+	}
+}
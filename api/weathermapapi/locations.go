@@ -0,0 +1,52 @@
+package weathermapapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+)
+
+//DefaultLocationsFile is the path, relative to the repository root, of the locations shipped with led-map.
+const DefaultLocationsFile = "api/weathermapapi/defaultLocations.json"
+
+//Location is a city on the map. Its position in a list of locations is the index of the LED that shows it.
+type Location struct {
+	LocationID string  `json:"locationId"`
+	City       string  `json:"city"`
+	State      string  `json:"state"`
+	Country    string  `json:"country"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+}
+
+//LoadLocations reads a locations file and returns the locations ordered by LED index.
+//The file is a JSON object whose keys are LED indexes, like defaultLocations.json.
+func LoadLocations(path string) ([]Location, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return []Location{}, err
+	}
+	return ParseLocations(data)
+}
+
+//ParseLocations works like LoadLocations, but reads the locations from memory.
+func ParseLocations(data []byte) ([]Location, error) {
+	byIndex := make(map[string]Location)
+	err := json.Unmarshal(data, &byIndex)
+	if err != nil {
+		return []Location{}, err
+	}
+
+	locations := make([]Location, len(byIndex))
+	seen := make([]bool, len(byIndex))
+	for key, location := range byIndex {
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index >= len(locations) || seen[index] {
+			return []Location{}, fmt.Errorf("location keys must be unique LED indexes from 0 to %v, got: %q", len(locations)-1, key)
+		}
+		locations[index] = location
+		seen[index] = true
+	}
+	return locations, nil
+}
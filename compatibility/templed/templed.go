@@ -2,6 +2,7 @@ package templed
 
 import (
 	"fmt"
+	"led-map/timeline"
 	"led-map/utilities"
 )
 
@@ -10,69 +11,51 @@ type TempLister interface {
 	ListTemps() [][]float64
 }
 
-//GetColors receives something that can provide a table of temperatures, and returns a 3-dimensional array of colors.
-//For example, the array input [[12.5, 23.5, 32.0, 40.5], [23.4, 23.6, 67.5, 40.2]] will return
-func GetColors(t TempLister, fadeSteps int) ([][][]int, error) {
+//GetTimeline receives something that can provide a table of temperatures, one forecast per location, and returns
+//the map's timeline. Every forecast instant becomes a keyframe, with one color per location, followed by a transition
+//of fadeSteps-1 steps that fades each location to its next temperature. The last keyframe fades to itself.
+//Keyframes aren't held and steps have no time until the timeline is paced.
+//For example, the input [[12.5, 23.5], [23.4, 23.6]] returns two keyframes of two colors each.
+func GetTimeline(t TempLister, fadeSteps int) (timeline.Timeline, error) {
 	temps := t.ListTemps()
 	if len(temps) == 0 {
-		return [][][]int{}, fmt.Errorf("0 temps returned from TempLister")
+		return timeline.Timeline{}, fmt.Errorf("0 temps returned from TempLister")
 	}
-	forecastColors := make([][][]int, len(temps))
-	for i := range forecastColors {
-		forecastColors[i] = make([][]int, len(temps[0]))
+	if fadeSteps < 1 {
+		return timeline.Timeline{}, fmt.Errorf("at least 1 fade step is needed, got: %v", fadeSteps)
 	}
-	for i, forecast := range temps {
-		for j, temp := range forecast {
-			if j == 0 {
-				continue
-			}
-			colorFade, err := fade(forecast[j-1], temp, fadeSteps)
-			if err != nil {
-				return [][][]int{}, err
-			}
-			forecastColors[i][j-1] = colorFade
-		}
-		lastTemp := forecast[len(forecast)-1]
-		colorFade, err := fade(lastTemp, lastTemp, fadeSteps)
-		if err != nil {
-			return [][][]int{}, err
-		}
-		forecastColors[i][len(forecast)-1] = colorFade
+	forecastLength := len(temps[0])
+	forecastColors := timeline.Timeline{
+		Keyframes:   make([]timeline.Keyframe, forecastLength),
+		Transitions: make([]timeline.Transition, forecastLength),
 	}
-
-	return reorderForecastColorHierarchy(forecastColors), nil
-}
-
-func reorderForecastColorHierarchy(forecastColors [][][]int) [][][]int {
-	//At this point, we have a hierarchy: location > forecast > colors to fade between
-	//To be most useful to our map, we need to set all locations, one color at a time, meaning that
-	//we have to reorder this hierarchy to forecast > colors > locations. This is not easy. Buckle in.
-
-	forecastLength := len(forecastColors[0]) //len(forecastColors[0]) is the number of weather instances at a single location, i.e. the length of the forecast
-	fadeLength := len(forecastColors[0][0])  //len(forecastColors[0][0]) is the number of colors per weather instance
-	numLocations := len(forecastColors)      //len(forecastColors) is the number of locations, which will coincide with the number of LEDs
-
-	//The base array must be long enough to hold the forecasts
-	unpivotedColors := make([][][]int, forecastLength)
-	for i := range unpivotedColors {
-		//The second-level array must be long enough to hold each fade-group
-		unpivotedColors[i] = make([][]int, fadeLength)
-		for j := range unpivotedColors[i] {
-			//The third-level array must be long enough to hold one int for each location
-			unpivotedColors[i][j] = make([]int, numLocations)
+	for i := range forecastColors.Keyframes {
+		forecastColors.Keyframes[i].Colors = make([]int, len(temps))
+		forecastColors.Transitions[i] = make(timeline.Transition, fadeSteps-1)
+		for j := range forecastColors.Transitions[i] {
+			forecastColors.Transitions[i][j] = make([]int, len(temps))
 		}
 	}
-
-	//This hurts my brain. It works, but it hurts.
-	for i := 0; i < forecastLength; i++ {
-		for j := 0; j < fadeLength; j++ {
-			for k := 0; k < numLocations; k++ {
-				unpivotedColors[i][j][k] = forecastColors[k][i][j]
+	for location, forecast := range temps {
+		if len(forecast) != forecastLength {
+			return timeline.Timeline{}, fmt.Errorf("location %v has %v temps, but the first location has %v", location, len(forecast), forecastLength)
+		}
+		for i, temp := range forecast {
+			next := temp
+			if i+1 < len(forecast) {
+				next = forecast[i+1]
+			}
+			colorFade, err := fade(temp, next, fadeSteps)
+			if err != nil {
+				return timeline.Timeline{}, err
+			}
+			forecastColors.Keyframes[i].Colors[location] = colorFade[0]
+			for j, color := range colorFade[1:] {
+				forecastColors.Transitions[i][j][location] = color
 			}
 		}
 	}
-
-	return unpivotedColors
+	return forecastColors, nil
 }
 
 //fade returns a list of color values representing a fade between two temperatures.
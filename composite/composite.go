@@ -0,0 +1,219 @@
+//Package composite joins several LED backends into one logical strip.
+//A map can be spread across both ws281x channels and a network node, for example, and LedMap still
+//fills the whole map with a single call. The composite splits every frame between the backends,
+//and renders them all together.
+package composite
+
+import (
+	"fmt"
+	"led-map/ledmap"
+	"led-map/ledstrip"
+	"sync"
+)
+
+//Segment is a run of the logical strip that is shown by one backend.
+//Segments are laid end to end in the order they are given: the first LED of a segment comes right after the last LED of the one before it.
+type Segment struct {
+	Leds     ledmap.ColorFiller //the backend that shows the segment. It should not autoFill, so every backend renders together.
+	LedCount int                //total number of LEDs on the backend
+	Offset   int                //index on the backend of the segment's first physical LED
+	Length   int                //number of logical LEDs in the segment
+	Reversed bool               //the segment runs from its last physical LED back towards Offset
+	Skip     []int              //backend indexes inside the segment that are left dark, such as LEDs hidden behind the frame
+}
+
+//Composite is a ColorFiller that maps one logical index space onto several backends.
+type Composite struct {
+	mu        sync.Mutex
+	backends  []*backend
+	renderers []ledmap.ColorFiller //what has to be rendered to render every backend, each of them once
+	leds      []location           //where each logical LED lives
+}
+
+//channel is a backend that is one channel of a larger strip, such as a ledstrip.ChannelStrip.
+//Rendering it renders the whole strip.
+type channel interface {
+	Strip() *ledstrip.LedStrip
+}
+
+//backend is one of the ColorFillers behind the composite, along with the full frame it was last given.
+type backend struct {
+	leds   ledmap.ColorFiller
+	colors []int
+}
+
+//location is the backend and index a logical LED is shown on.
+type location struct {
+	backend *backend
+	index   int
+}
+
+//New returns a Composite made of the supplied segments.
+//Several segments can share a backend, as long as they don't use the same LEDs on it.
+//Segments can also be different channels of the same LedStrip: the strip is still only rendered once per frame.
+//Example:
+//	leds, err := composite.New(
+//		composite.Segment{Leds: channel0, LedCount: 60, Length: 60},
+//		composite.Segment{Leds: channel1, LedCount: 45, Offset: 2, Length: 40, Reversed: true, Skip: []int{20}},
+//		composite.Segment{Leds: node, LedCount: 30, Length: 30},
+//	)
+func New(segments ...Segment) (*Composite, error) {
+	c := &Composite{
+		backends: make([]*backend, 0),
+		leds:     make([]location, 0),
+	}
+	used := make(map[location]bool)
+	for i, segment := range segments {
+		b, err := c.backend(segment)
+		if err != nil {
+			return &Composite{}, fmt.Errorf("segment %v: %v", i, err)
+		}
+		indexes, err := segment.indexes()
+		if err != nil {
+			return &Composite{}, fmt.Errorf("segment %v: %v", i, err)
+		}
+		for _, index := range indexes {
+			led := location{b, index}
+			if used[led] {
+				return &Composite{}, fmt.Errorf("segment %v: LED %v is already used by another segment", i, index)
+			}
+			used[led] = true
+			c.leds = append(c.leds, led)
+		}
+	}
+	c.renderers = make([]ledmap.ColorFiller, 0, len(c.backends))
+	rendered := make(map[ledmap.ColorFiller]bool)
+	for _, b := range c.backends {
+		renderer := b.leds
+		if ch, ok := b.leds.(channel); ok {
+			renderer = ch.Strip()
+		}
+		if !rendered[renderer] {
+			rendered[renderer] = true
+			c.renderers = append(c.renderers, renderer)
+		}
+	}
+	return c, nil
+}
+
+//backend returns the backend for a segment, reusing it if an earlier segment already added it.
+func (c *Composite) backend(segment Segment) (*backend, error) {
+	if segment.Leds == nil {
+		return nil, fmt.Errorf("no LEDs given")
+	}
+	for _, b := range c.backends {
+		if b.leds == segment.Leds {
+			if len(b.colors) != segment.LedCount {
+				return nil, fmt.Errorf("LED count %v doesn't match the %v given by an earlier segment with the same LEDs", segment.LedCount, len(b.colors))
+			}
+			return b, nil
+		}
+	}
+	if segment.LedCount <= 0 {
+		return nil, fmt.Errorf("LED count must be positive, got: %v", segment.LedCount)
+	}
+	b := &backend{leds: segment.Leds, colors: make([]int, segment.LedCount)}
+	c.backends = append(c.backends, b)
+	return b, nil
+}
+
+//indexes returns the backend index of each of the segment's logical LEDs, in logical order.
+func (s Segment) indexes() ([]int, error) {
+	if s.Length < 0 || s.Offset < 0 {
+		return nil, fmt.Errorf("offset and length can't be negative, got: %v and %v", s.Offset, s.Length)
+	}
+	skip := make(map[int]bool, len(s.Skip))
+	for _, index := range s.Skip {
+		skip[index] = true
+	}
+	indexes := make([]int, 0, s.Length)
+	for index := s.Offset; len(indexes) < s.Length; index++ {
+		if index >= s.LedCount {
+			return nil, fmt.Errorf("%v LEDs starting at %v, skipping %v, don't fit on %v LEDs", s.Length, s.Offset, s.Skip, s.LedCount)
+		}
+		if !skip[index] {
+			indexes = append(indexes, index)
+		}
+	}
+	if s.Reversed {
+		for i, j := 0, len(indexes)-1; i < j; i, j = i+1, j-1 {
+			indexes[i], indexes[j] = indexes[j], indexes[i]
+		}
+	}
+	return indexes, nil
+}
+
+//Len returns the number of logical LEDs in the composite.
+func (c *Composite) Len() int {
+	return len(c.leds)
+}
+
+//FillSingle applies a color to all logical LEDs. Skipped and unused LEDs on the backends are left as they were.
+func (c *Composite) FillSingle(color int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, led := range c.leds {
+		led.backend.colors[led.index] = color
+	}
+	return c.fillBackends()
+}
+
+//Fill applies an array of colors to all logical LEDs. The array of colors must be the same length as the composite.
+func (c *Composite) Fill(colors []int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(colors) != len(c.leds) {
+		return fmt.Errorf("mismatch between number of colors and number of LEDs. colors = %v, LEDs = %v", len(colors), len(c.leds))
+	}
+	for i, led := range c.leds {
+		led.backend.colors[led.index] = colors[i]
+	}
+	return c.fillBackends()
+}
+
+//fillBackends hands every backend its share of the frame. The caller must hold the lock.
+func (c *Composite) fillBackends() error {
+	for _, b := range c.backends {
+		err := b.leds.Fill(b.colors)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Set sets a single logical LED's color.
+func (c *Composite) Set(index int, color int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if index >= len(c.leds) || index < 0 {
+		return fmt.Errorf("index is out of bounds")
+	}
+	led := c.leds[index]
+	led.backend.colors[led.index] = color
+	return led.backend.leds.Set(led.index, color)
+}
+
+//Render renders every backend at the same time, so all segments change together. Channels of the same LedStrip
+//are rendered together, by rendering their strip once.
+//If any backend fails, the first error is returned once every backend has finished.
+func (c *Composite) Render() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	errs := make([]error, len(c.renderers))
+	var wg sync.WaitGroup
+	for i, renderer := range c.renderers {
+		wg.Add(1)
+		go func(i int, renderer ledmap.ColorFiller) {
+			defer wg.Done()
+			errs[i] = renderer.Render()
+		}(i, renderer)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+//Package diagnostics shows standard test patterns on any ColorFiller, so a freshly built map can be checked
+//before it is loaded with forecasts.
+//Every pattern comes with an instruction saying what the LEDs should look like, which Run reports as the pattern
+//is shown. LEDs that don't match point at a problem:
+//	Primaries       LEDs that stay dark are dead, LEDs showing the wrong color have their channels in the wrong order
+//	WalkingPixel    an LED lighting up out of turn, or two at once, is wired in the wrong place
+//	BrightnessRamp  LEDs that flicker, jump or change color while dimming have a bad supply or data line
+//	BinaryIndex     reading each LED's colors over every frame spells out its index in binary, so a photo of each
+//	                frame is enough to find every LED that is out of order
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"led-map/ledcolor"
+	"led-map/ledmap"
+	"strings"
+	"time"
+)
+
+//Pattern is one frame of a test, and how long it is held.
+type Pattern struct {
+	Test        string //name of the test the frame belongs to
+	Instruction string //what the LEDs should look like
+	Colors      []int
+	Hold        time.Duration
+}
+
+//Colors used by the tests
+var (
+	red   = ledcolor.Color{R: 255}.Grb()
+	green = ledcolor.Color{G: 255}.Grb()
+	blue  = ledcolor.Color{B: 255}.Grb()
+	white = ledcolor.Color{R: 255, G: 255, B: 255}.Grb()
+)
+
+//Tests are the names of every test, in the order All runs them.
+var Tests = []string{"primaries", "walk", "ramp", "binary"}
+
+//Primaries shows every LED red, then green, then blue, then white.
+func Primaries(ledCount int) []Pattern {
+	primaries := []struct {
+		name  string
+		color int
+	}{{"red", red}, {"green", green}, {"blue", blue}, {"white", white}}
+	patterns := make([]Pattern, len(primaries))
+	for i, primary := range primaries {
+		patterns[i] = Pattern{
+			Test:        "primaries",
+			Instruction: fmt.Sprintf("every LED is %v. Dark LEDs are dead, LEDs of another color have the wrong channel order", primary.name),
+			Colors:      fill(ledCount, primary.color),
+			Hold:        3 * time.Second,
+		}
+	}
+	return patterns
+}
+
+//WalkingPixel lights one LED at a time, from the first to the last.
+func WalkingPixel(ledCount int) []Pattern {
+	patterns := make([]Pattern, ledCount)
+	for i := range patterns {
+		colors := make([]int, ledCount)
+		colors[i] = white
+		patterns[i] = Pattern{
+			Test:        "walk",
+			Instruction: fmt.Sprintf("only LED %v of %v is lit", i, ledCount),
+			Colors:      colors,
+			Hold:        250 * time.Millisecond,
+		}
+	}
+	return patterns
+}
+
+//BrightnessRamp fades every LED from off to full white in the supplied number of steps, then back down.
+func BrightnessRamp(ledCount int, steps int) []Pattern {
+	patterns := make([]Pattern, 0, 2*steps+1)
+	for i := -steps; i <= steps; i++ {
+		step := steps - abs(i)
+		level := uint8(255 * step / steps)
+		patterns = append(patterns, Pattern{
+			Test:        "ramp",
+			Instruction: "every LED fades evenly from off to full white and back, without flickering or changing color",
+			Colors:      fill(ledCount, ledcolor.Color{R: level, G: level, B: level}.Grb()),
+			Hold:        40 * time.Millisecond,
+		})
+	}
+	return patterns
+}
+
+//BinaryIndex shows every LED's index in binary, one bit per frame, most significant bit first.
+//LEDs whose index has the bit set are green, the rest are red, so a dead LED is never mistaken for a 0.
+func BinaryIndex(ledCount int) []Pattern {
+	bits := 1
+	for 1<<uint(bits) < ledCount {
+		bits++
+	}
+	patterns := make([]Pattern, bits)
+	for b := 0; b < bits; b++ {
+		bit := bits - 1 - b
+		colors := make([]int, ledCount)
+		for i := range colors {
+			colors[i] = red
+			if i>>uint(bit)&1 == 1 {
+				colors[i] = green
+			}
+		}
+		patterns[b] = Pattern{
+			Test:        "binary",
+			Instruction: fmt.Sprintf("bit %v of %v: LEDs whose index has a 1 here (value %v) are green, the rest are red", b+1, bits, 1<<uint(bit)),
+			Colors:      colors,
+			Hold:        5 * time.Second,
+		}
+	}
+	return patterns
+}
+
+//All returns every test's patterns, in the order of Tests.
+func All(ledCount int) []Pattern {
+	patterns, _ := Named(ledCount, Tests...)
+	return patterns
+}
+
+//Named returns the patterns of the named tests, in the order they are named.
+func Named(ledCount int, tests ...string) ([]Pattern, error) {
+	patterns := make([]Pattern, 0)
+	for _, test := range tests {
+		switch strings.ToLower(strings.TrimSpace(test)) {
+		case "primaries":
+			patterns = append(patterns, Primaries(ledCount)...)
+		case "walk":
+			patterns = append(patterns, WalkingPixel(ledCount)...)
+		case "ramp":
+			patterns = append(patterns, BrightnessRamp(ledCount, 50)...)
+		case "binary":
+			patterns = append(patterns, BinaryIndex(ledCount)...)
+		default:
+			return nil, fmt.Errorf("unknown test %q, expected one of %v", test, Tests)
+		}
+	}
+	return patterns, nil
+}
+
+//Run shows the patterns on leds one after another, and writes each pattern's instruction to report as it is shown.
+//Patterns whose instruction is the same as the one before, such as the steps of a ramp, aren't reported again.
+//Closing stop ends the run after the current pattern, with ledmap.ErrStopped.
+//Example:
+//	err := diagnostics.Run(strip, diagnostics.All(100), os.Stdout, nil)
+func Run(leds ledmap.ColorFiller, patterns []Pattern, report io.Writer, stop <-chan struct{}) error {
+	test, instruction := "", ""
+	for _, p := range patterns {
+		err := leds.Fill(p.Colors)
+		if err != nil {
+			return err
+		}
+		err = leds.Render()
+		if err != nil {
+			return err
+		}
+		if p.Test != test {
+			fmt.Fprintf(report, "== %v\n", p.Test)
+			test = p.Test
+		}
+		if p.Instruction != instruction {
+			fmt.Fprintf(report, "%v\n", p.Instruction)
+			instruction = p.Instruction
+		}
+		select {
+		case <-stop:
+			return ledmap.ErrStopped
+		case <-time.After(p.Hold):
+		}
+	}
+	return nil
+}
+
+//fill returns ledCount LEDs of the same color.
+func fill(ledCount int, color int) []int {
+	colors := make([]int, ledCount)
+	for i := range colors {
+		colors[i] = color
+	}
+	return colors
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,36 @@
+package dmx
+
+import "encoding/binary"
+
+//Fields of an ArtDmx packet, from the Art-Net 4 specification
+const (
+	artNetOpDmx        = 0x5000
+	artNetProtocol     = 14
+	artNetHeaderLength = 18
+)
+
+//artNetIdentifier is the ID every Art-Net packet starts with.
+var artNetIdentifier = []byte("Art-Net\x00")
+
+//artDmxPacket builds an ArtDmx packet carrying the supplied DMX slots to a 15 bit port-address.
+func artDmxPacket(portAddress int, sequence byte, slots []byte) []byte {
+	length := len(slots)
+	//The data length has to be even, and at least 2
+	if length < 2 {
+		length = 2
+	}
+	if length%2 != 0 {
+		length++
+	}
+	packet := make([]byte, artNetHeaderLength+length)
+	copy(packet, artNetIdentifier)
+	binary.LittleEndian.PutUint16(packet[8:], artNetOpDmx)
+	binary.BigEndian.PutUint16(packet[10:], artNetProtocol)
+	packet[12] = sequence
+	packet[13] = 0                           //physical input port, informational only
+	packet[14] = byte(portAddress)           //SubUni: sub-net and universe
+	packet[15] = byte(portAddress>>8) & 0x7f //Net
+	binary.BigEndian.PutUint16(packet[16:], uint16(length))
+	copy(packet[artNetHeaderLength:], slots)
+	return packet
+}
@@ -0,0 +1,357 @@
+//Package dmx sends LED frames over the network as E1.31 (sACN) or Art-Net DMX packets.
+//Pixel controllers such as ESP32 boards, WLED nodes and lighting consoles all understand at least one of them,
+//so a DMX is a ColorFiller that can drive a map that isn't attached to the Pi at all.
+//
+//Every LED takes one DMX slot per color channel. Pixels are never split across universes: when a universe
+//can't hold another whole pixel, the next pixel starts at address 1 of the next universe.
+package dmx
+
+import (
+	"fmt"
+	"led-map/ledcolor"
+	"net"
+	"strconv"
+	"sync"
+)
+
+//Protocol is the DMX over IP protocol frames are sent with.
+type Protocol int
+
+const (
+	//E131 is ANSI E1.31, also known as streaming ACN or sACN.
+	E131 Protocol = iota
+	//ArtNet is Art-Net 4.
+	ArtNet
+)
+
+const (
+	//UniverseSize is the number of slots in a DMX universe.
+	UniverseSize = 512
+	//E131Port is the UDP port E1.31 receivers listen on.
+	E131Port = 5568
+	//ArtNetPort is the UDP port Art-Net nodes listen on.
+	ArtNetPort = 6454
+)
+
+//DMX is a ColorFiller that sends frames to a DMX over IP receiver.
+type DMX struct {
+	mu         sync.Mutex
+	conn       net.PacketConn
+	host       string //receiver address, empty for multicast (E1.31) or broadcast (Art-Net)
+	protocol   Protocol
+	pending    []int
+	autoFill   bool
+	universe   int            //first universe
+	start      int            //address of the first pixel's first slot in the first universe, from 1
+	order      ledcolor.Order //order the receiver expects its color channels in
+	sourceName string
+	priority   int
+	cid        [16]byte
+	sequences  map[int]byte //last sequence number sent on each universe
+	universes  []universe
+}
+
+//universe is the part of a frame that is sent in one DMX universe.
+type universe struct {
+	number int
+	start  int //slot of the first pixel, from 0
+	first  int //index of the first LED in the universe
+	count  int //number of LEDs in the universe
+	dest   net.Addr
+}
+
+//Function used to set options
+type option func(*DMX)
+
+//New returns a DMX that sends the colors of ledCount LEDs to the receiver at host.
+//Host can include a port, otherwise the protocol's standard port is used. An empty host sends E1.31 to the
+//standard multicast group of each universe, and Art-Net to the broadcast address.
+//AutoFill behaves the same way it does for ledstrip.LedStrip.
+//Example:
+//	node, err := dmx.New("192.168.1.50", 100, false, dmx.Using(dmx.ArtNet), dmx.Universe(1))
+func New(host string, ledCount int, autoFill bool, opts ...option) (*DMX, error) {
+	d := &DMX{
+		host:       host,
+		protocol:   E131,
+		pending:    make([]int, ledCount),
+		autoFill:   autoFill,
+		universe:   1,
+		start:      1,
+		order:      ledcolor.RGB,
+		sourceName: "led-map",
+		priority:   100,
+		sequences:  make(map[int]byte),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	err := d.validate()
+	if err != nil {
+		return &DMX{}, err
+	}
+	err = d.resolve()
+	if err != nil {
+		return &DMX{}, err
+	}
+	d.conn, err = net.ListenPacket("udp", ":0")
+	if err != nil {
+		return &DMX{}, err
+	}
+	return d, nil
+}
+
+//Using provides an option for choosing between E1.31, the default, and Art-Net.
+func Using(protocol Protocol) option {
+	return func(d *DMX) {
+		d.protocol = protocol
+	}
+}
+
+//Universe provides an option for setting the universe of the first LED. Later LEDs continue in the universes after it.
+//E1.31 universes start at 1, Art-Net port-addresses start at 0.
+func Universe(number int) option {
+	return func(d *DMX) {
+		d.universe = number
+	}
+}
+
+//StartAddress provides an option for setting the DMX address, from 1 to 512, of the first LED's first channel.
+func StartAddress(address int) option {
+	return func(d *DMX) {
+		d.start = address
+	}
+}
+
+//Order provides an option for setting the order the receiver expects color channels in. It is RGB by default.
+func Order(order ledcolor.Order) option {
+	return func(d *DMX) {
+		d.order = order
+	}
+}
+
+//SourceName provides an option for setting the E1.31 source name shown by receivers. It is ignored by Art-Net.
+func SourceName(name string) option {
+	return func(d *DMX) {
+		d.sourceName = name
+	}
+}
+
+//Priority provides an option for setting the E1.31 priority, from 0 to 200. It is ignored by Art-Net.
+func Priority(priority int) option {
+	return func(d *DMX) {
+		d.priority = priority
+	}
+}
+
+//CID provides an option for setting the E1.31 component identifier, which should stay the same for the life of
+//an installation. By default one is derived from the source name.
+func CID(cid [16]byte) option {
+	return func(d *DMX) {
+		d.cid = cid
+	}
+}
+
+func (d *DMX) validate() error {
+	err := d.order.Validate()
+	if err != nil {
+		return err
+	}
+	if d.start < 1 || d.start+d.order.Channels()-1 > UniverseSize {
+		return fmt.Errorf("start address must leave room for a pixel between 1 and %v, got: %v", UniverseSize, d.start)
+	}
+	switch d.protocol {
+	case E131:
+		if d.universe < 1 || d.universe > 63999 {
+			return fmt.Errorf("E1.31 universes must be between 1 and 63999, got: %v", d.universe)
+		}
+		if d.priority < 0 || d.priority > 200 {
+			return fmt.Errorf("E1.31 priority must be between 0 and 200, got: %v", d.priority)
+		}
+		if len(d.sourceName) > 63 {
+			return fmt.Errorf("E1.31 source names can't be longer than 63 bytes, got: %q", d.sourceName)
+		}
+	case ArtNet:
+		if d.universe < 0 || d.universe > 32767 {
+			return fmt.Errorf("Art-Net port-addresses must be between 0 and 32767, got: %v", d.universe)
+		}
+	default:
+		return fmt.Errorf("unknown DMX protocol %v", d.protocol)
+	}
+	if d.cid == [16]byte{} {
+		d.cid = defaultCID(d.sourceName)
+	}
+	return nil
+}
+
+//resolve splits the LEDs into universes and works out where each universe is sent.
+func (d *DMX) resolve() error {
+	pixelSize := d.order.Channels()
+	slot := d.start - 1
+	number := d.universe
+	first := 0
+	d.universes = make([]universe, 0)
+	for first < len(d.pending) {
+		count := (UniverseSize - slot) / pixelSize
+		if count > len(d.pending)-first {
+			count = len(d.pending) - first
+		}
+		dest, err := d.destination(number)
+		if err != nil {
+			return err
+		}
+		d.universes = append(d.universes, universe{
+			number: number,
+			start:  slot,
+			first:  first,
+			count:  count,
+			dest:   dest,
+		})
+		first += count
+		number++
+		slot = 0
+	}
+	last := d.universe + len(d.universes) - 1
+	if (d.protocol == E131 && last > 63999) || (d.protocol == ArtNet && last > 32767) {
+		return fmt.Errorf("%v LEDs need universes up to %v, which is past the last universe", len(d.pending), last)
+	}
+	return nil
+}
+
+//destination returns the address a universe is sent to.
+func (d *DMX) destination(number int) (net.Addr, error) {
+	host, port := d.host, 0
+	if h, p, err := net.SplitHostPort(d.host); err == nil {
+		host = h
+		port, err = strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in %q", d.host)
+		}
+	}
+	if port == 0 {
+		port = E131Port
+		if d.protocol == ArtNet {
+			port = ArtNetPort
+		}
+	}
+	if host == "" {
+		if d.protocol == E131 {
+			host = fmt.Sprintf("239.255.%d.%d", number>>8, number&0xff)
+		} else {
+			host = "255.255.255.255"
+		}
+	}
+	return net.ResolveUDPAddr("udp", net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+//FillSingle applies a color to all LEDs.
+func (d *DMX) FillSingle(color int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.pending {
+		d.pending[i] = color
+	}
+	if d.autoFill {
+		return d.render()
+	}
+	return nil
+}
+
+//Fill applies an array of colors to all LEDs. The array of colors must be the same length as the number of LEDs.
+func (d *DMX) Fill(colors []int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(colors) != len(d.pending) {
+		return fmt.Errorf("mismatch between number of colors and number of LEDs. colors = %v, LEDs = %v", len(colors), len(d.pending))
+	}
+	copy(d.pending, colors)
+	if d.autoFill {
+		return d.render()
+	}
+	return nil
+}
+
+//Set sets a single LED's color.
+func (d *DMX) Set(index int, color int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if index >= len(d.pending) || index < 0 {
+		return fmt.Errorf("index is out of bounds")
+	}
+	d.pending[index] = color
+	if d.autoFill {
+		return d.render()
+	}
+	return nil
+}
+
+//Render sends one packet for every universe the LEDs are spread over.
+//If autoFill is true, there's no reason to use this.
+func (d *DMX) Render() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.render()
+}
+
+//render sends the pending colors. The caller must hold the lock.
+func (d *DMX) render() error {
+	for _, u := range d.universes {
+		slots := d.slots(u)
+		sequence := d.nextSequence(u.number)
+		var packet []byte
+		if d.protocol == ArtNet {
+			packet = artDmxPacket(u.number, sequence, slots)
+		} else {
+			packet = e131Packet(d.cid, d.sourceName, byte(d.priority), u.number, sequence, slots)
+		}
+		_, err := d.conn.WriteTo(packet, u.dest)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//slots returns the DMX data of one universe, up to the last slot that is used.
+func (d *DMX) slots(u universe) []byte {
+	pixelSize := d.order.Channels()
+	slots := make([]byte, u.start+u.count*pixelSize)
+	for i := 0; i < u.count; i++ {
+		color := ledcolor.FromGrb(d.pending[u.first+i])
+		if d.order.HasWhite() {
+			color = color.ExtractWhite()
+		}
+		copy(slots[u.start+i*pixelSize:], d.order.Bytes(color))
+	}
+	return slots
+}
+
+//nextSequence returns the sequence number of the next packet on a universe.
+//Art-Net reserves 0 to mean sequencing is off, so its sequence numbers go from 1 to 255.
+func (d *DMX) nextSequence(number int) byte {
+	sequence := d.sequences[number] + 1
+	if sequence == 0 && d.protocol == ArtNet {
+		sequence = 1
+	}
+	d.sequences[number] = sequence
+	return sequence
+}
+
+//Universes returns the universe numbers the LEDs are spread over, in order.
+func (d *DMX) Universes() []int {
+	numbers := make([]int, len(d.universes))
+	for i, u := range d.universes {
+		numbers[i] = u.number
+	}
+	return numbers
+}
+
+//Deinit turns every LED off and closes the network connection.
+func (d *DMX) Deinit() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range d.pending {
+		d.pending[i] = 0
+	}
+	d.render()
+	d.conn.Close()
+}
@@ -0,0 +1,207 @@
+package dmx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+//listen opens a UDP socket on the loopback interface for a DMX to send to.
+func listen(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+//receive reads the next packet sent to conn.
+func receive(t *testing.T, conn *net.UDPConn) []byte {
+	t.Helper()
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[:n]
+}
+
+//frame returns ledCount colors, each of them different.
+func frame(ledCount int) []int {
+	colors := make([]int, ledCount)
+	for i := range colors {
+		colors[i] = 0x010203 * (i + 1)
+	}
+	return colors
+}
+
+func TestE131(t *testing.T) {
+	conn := listen(t)
+	d, err := New(conn.LocalAddr().String(), 200, false, SourceName("test"), Priority(150))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Deinit()
+	if got := d.Universes(); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("200 RGB LEDs should use universes [1 2], got: %v", got)
+	}
+	err = d.Fill(frame(200))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for render := 1; render <= 2; render++ {
+		err = d.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, leds := range []int{170, 30} {
+			packet := receive(t, conn)
+			universe := i + 1
+			if len(packet) != e131HeaderLength+leds*3 {
+				t.Fatalf("universe %v: packet is %v bytes long, expected %v", universe, len(packet), e131HeaderLength+leds*3)
+			}
+			if binary.BigEndian.Uint16(packet[0:]) != 0x0010 || !bytes.Equal(packet[4:16], e131Identifier) {
+				t.Errorf("universe %v: bad root layer preamble: % x", universe, packet[:16])
+			}
+			if binary.BigEndian.Uint16(packet[16:])&0x0fff != uint16(len(packet)-16) {
+				t.Errorf("universe %v: root layer length %v, expected %v", universe, binary.BigEndian.Uint16(packet[16:])&0x0fff, len(packet)-16)
+			}
+			if binary.BigEndian.Uint32(packet[18:]) != e131RootVector || binary.BigEndian.Uint32(packet[40:]) != e131FrameVector {
+				t.Errorf("universe %v: bad root or framing vector", universe)
+			}
+			if name := string(bytes.TrimRight(packet[44:108], "\x00")); name != "test" {
+				t.Errorf("universe %v: source name %q, expected %q", universe, name, "test")
+			}
+			if packet[108] != 150 {
+				t.Errorf("universe %v: priority %v, expected 150", universe, packet[108])
+			}
+			if packet[111] != byte(render) {
+				t.Errorf("universe %v: sequence %v, expected %v", universe, packet[111], render)
+			}
+			if got := binary.BigEndian.Uint16(packet[113:]); got != uint16(universe) {
+				t.Errorf("universe %v: packet is for universe %v", universe, got)
+			}
+			if packet[117] != e131DmpVector || packet[118] != e131AddressType {
+				t.Errorf("universe %v: bad DMP layer header", universe)
+			}
+			if got := binary.BigEndian.Uint16(packet[123:]); got != uint16(leds*3+1) {
+				t.Errorf("universe %v: property value count %v, expected %v", universe, got, leds*3+1)
+			}
+			if packet[125] != 0 {
+				t.Errorf("universe %v: start code %v, expected 0", universe, packet[125])
+			}
+			//The first LED of the universe, in RGB order
+			first := 0x010203 * (i*170 + 1)
+			expected := []byte{byte(first >> 8), byte(first >> 16), byte(first)}
+			if !bytes.Equal(packet[126:129], expected) {
+				t.Errorf("universe %v: first LED is % x, expected % x", universe, packet[126:129], expected)
+			}
+		}
+	}
+}
+
+func TestArtNet(t *testing.T) {
+	conn := listen(t)
+	d, err := New(conn.LocalAddr().String(), 200, false, Using(ArtNet), Universe(0x0123))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Deinit()
+	err = d.Fill(frame(200))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for render := 1; render <= 2; render++ {
+		err = d.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, leds := range []int{170, 30} {
+			packet := receive(t, conn)
+			portAddress := 0x0123 + i
+			if !bytes.Equal(packet[:8], artNetIdentifier) {
+				t.Errorf("universe %v: bad ID % x", portAddress, packet[:8])
+			}
+			if binary.LittleEndian.Uint16(packet[8:]) != artNetOpDmx || binary.BigEndian.Uint16(packet[10:]) != artNetProtocol {
+				t.Errorf("universe %v: bad opcode or protocol version", portAddress)
+			}
+			if packet[12] != byte(render) {
+				t.Errorf("universe %v: sequence %v, expected %v", portAddress, packet[12], render)
+			}
+			if packet[14] != byte(portAddress) || packet[15] != byte(portAddress>>8) {
+				t.Errorf("universe %v: SubUni %#x and Net %#x don't match", portAddress, packet[14], packet[15])
+			}
+			if got := binary.BigEndian.Uint16(packet[16:]); got != uint16(leds*3) || len(packet) != artNetHeaderLength+leds*3 {
+				t.Errorf("universe %v: length %v in a %v byte packet, expected %v", portAddress, got, len(packet), leds*3)
+			}
+		}
+	}
+}
+
+func TestArtNetSequenceSkipsZero(t *testing.T) {
+	d := &DMX{protocol: ArtNet, sequences: map[int]byte{0: 255}}
+	if got := d.nextSequence(0); got != 1 {
+		t.Errorf("Art-Net sequence after 255 should be 1, got: %v", got)
+	}
+	d = &DMX{protocol: E131, sequences: map[int]byte{1: 255}}
+	if got := d.nextSequence(1); got != 0 {
+		t.Errorf("E1.31 sequence after 255 should be 0, got: %v", got)
+	}
+}
+
+func TestPixelsAreNotSplitAcrossUniverses(t *testing.T) {
+	conn := listen(t)
+	//Only one pixel fits between address 508 and the end of the first universe
+	d, err := New(conn.LocalAddr().String(), 4, false, StartAddress(508))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Deinit()
+	err = d.Fill(frame(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := receive(t, conn)
+	if len(first) != e131HeaderLength+510 {
+		t.Fatalf("first universe carries %v slots, expected 510", len(first)-e131HeaderLength)
+	}
+	if !bytes.Equal(first[e131HeaderLength+507:], []byte{0x02, 0x01, 0x03}) {
+		t.Errorf("first LED should be at address 508, got: % x", first[e131HeaderLength+504:])
+	}
+	second := receive(t, conn)
+	if binary.BigEndian.Uint16(second[113:]) != 2 {
+		t.Errorf("second packet should be for universe 2, got: %v", binary.BigEndian.Uint16(second[113:]))
+	}
+	if len(second) != e131HeaderLength+9 {
+		t.Errorf("second universe carries %v slots, expected 9", len(second)-e131HeaderLength)
+	}
+	if !bytes.Equal(second[e131HeaderLength:e131HeaderLength+3], []byte{0x04, 0x02, 0x06}) {
+		t.Errorf("second LED should start the second universe, got: % x", second[e131HeaderLength:e131HeaderLength+3])
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for name, opts := range map[string][]option{
+		"split pixel":        {StartAddress(511)},
+		"universe 0":         {Universe(0)},
+		"priority":           {Priority(201)},
+		"Art-Net port range": {Using(ArtNet), Universe(32768)},
+	} {
+		_, err := New("127.0.0.1", 10, false, opts...)
+		if err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package dmx
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+)
+
+//Fields of an E1.31 data packet, from ANSI E1.31-2016
+const (
+	e131RootVector    = 0x00000004 //VECTOR_ROOT_E131_DATA
+	e131FrameVector   = 0x00000002 //VECTOR_E131_DATA_PACKET
+	e131DmpVector     = 0x02       //VECTOR_DMP_SET_PROPERTY
+	e131AddressType   = 0xa1
+	e131HeaderLength  = 126 //everything up to and including the DMX start code
+	e131FramingOffset = 38
+	e131DmpOffset     = 115
+)
+
+//e131Identifier is the ACN packet identifier every E1.31 packet starts with, after the preamble.
+var e131Identifier = []byte{0x41, 0x53, 0x43, 0x2d, 0x45, 0x31, 0x2e, 0x31, 0x37, 0x00, 0x00, 0x00}
+
+//e131Packet builds an E1.31 data packet carrying the supplied DMX slots, with a null start code.
+func e131Packet(cid [16]byte, sourceName string, priority byte, universe int, sequence byte, slots []byte) []byte {
+	packet := make([]byte, e131HeaderLength+len(slots))
+
+	//Root layer
+	binary.BigEndian.PutUint16(packet[0:], 0x0010) //preamble size
+	binary.BigEndian.PutUint16(packet[2:], 0x0000) //postamble size
+	copy(packet[4:], e131Identifier)
+	binary.BigEndian.PutUint16(packet[16:], flagsAndLength(len(packet)-16))
+	binary.BigEndian.PutUint32(packet[18:], e131RootVector)
+	copy(packet[22:], cid[:])
+
+	//Framing layer
+	binary.BigEndian.PutUint16(packet[e131FramingOffset:], flagsAndLength(len(packet)-e131FramingOffset))
+	binary.BigEndian.PutUint32(packet[40:], e131FrameVector)
+	copy(packet[44:107], sourceName) //64 bytes, the last one is always left as the terminating null
+	packet[108] = priority
+	binary.BigEndian.PutUint16(packet[109:], 0) //synchronization address, unused
+	packet[111] = sequence
+	packet[112] = 0 //options
+	binary.BigEndian.PutUint16(packet[113:], uint16(universe))
+
+	//DMP layer
+	binary.BigEndian.PutUint16(packet[e131DmpOffset:], flagsAndLength(len(packet)-e131DmpOffset))
+	packet[117] = e131DmpVector
+	packet[118] = e131AddressType
+	binary.BigEndian.PutUint16(packet[119:], 0)                    //first property address
+	binary.BigEndian.PutUint16(packet[121:], 1)                    //address increment
+	binary.BigEndian.PutUint16(packet[123:], uint16(len(slots)+1)) //property value count, including the start code
+	packet[125] = 0                                                //DMX start code
+	copy(packet[e131HeaderLength:], slots)
+	return packet
+}
+
+//flagsAndLength packs a PDU length with the flags every E1.31 PDU uses.
+func flagsAndLength(length int) uint16 {
+	return 0x7000 | uint16(length&0x0fff)
+}
+
+//defaultCID derives a stable, version 5 style UUID from the source name, so a sender keeps the same CID across restarts.
+func defaultCID(sourceName string) [16]byte {
+	var cid [16]byte
+	sum := sha1.Sum([]byte("led-map/e131/" + sourceName))
+	copy(cid[:], sum[:16])
+	cid[6] = (cid[6] & 0x0f) | 0x50
+	cid[8] = (cid[8] & 0x3f) | 0x80
+	return cid
+}
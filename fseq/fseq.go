@@ -0,0 +1,149 @@
+//Package fseq reads and writes xLights .fseq sequence files, so the weather map can share a pipeline with
+//displays sequenced in xLights.
+//A Sequence is a list of frames shown one step apart. The map's timeline can be turned into one with FromTimeline
+//and written with Write, and any .fseq file can be read with Read and played through a LedMap as a Timeline.
+//
+//Both version 1 and version 2 files are supported. Version 2 files can be uncompressed or zlib compressed,
+//zstd compression isn't available in pure Go. Every LED takes three channels, in RGB order, the way xLights
+//lays out pixel models.
+package fseq
+
+import (
+	"fmt"
+	"led-map/timeline"
+	"time"
+)
+
+//Compression is the way frames are compressed in a version 2 file.
+type Compression int
+
+//Compression types, numbered the way they are stored in the file
+const (
+	None Compression = 0
+	Zstd Compression = 1
+	Zlib Compression = 2
+)
+
+//DefaultStepTime is the frame time xLights uses by default, 20 frames per second.
+const DefaultStepTime = 50 * time.Millisecond
+
+//MaxV2StepTime is the longest step time a version 2 file can store, a little under 4 frames per second.
+//Version 1 files can store step times up to 65535ms.
+const MaxV2StepTime = 255 * time.Millisecond
+
+//Sequence is a list of frames, each of them shown for StepTime.
+//Frames hold one color per LED, packed the way utilities.HsvToGrb packs them.
+type Sequence struct {
+	StepTime time.Duration
+	Frames   [][]int
+}
+
+//FromTimeline lays a timeline out as a sequence with the timeline's step time, which Write requires to be set.
+//Each keyframe is repeated for as many steps as it is held, and shown for at least one, and each transition
+//step after it is shown for one step.
+//Example:
+//	forecast, _ := templed.GetTimeline(temps, 40)
+//	forecast.Pace(10*time.Second, 3*time.Second, fseq.DefaultStepTime)
+//	seq := fseq.FromTimeline(forecast)
+func FromTimeline(t timeline.Timeline) Sequence {
+	seq := Sequence{StepTime: t.StepTime, Frames: make([][]int, 0)}
+	for i, keyframe := range t.Keyframes {
+		repeat := 1
+		if t.StepTime > 0 && keyframe.Hold > t.StepTime {
+			repeat = int(keyframe.Hold / t.StepTime)
+		}
+		for j := 0; j < repeat; j++ {
+			seq.Frames = append(seq.Frames, keyframe.Colors)
+		}
+		seq.Frames = append(seq.Frames, t.Transition(i)...)
+	}
+	return seq
+}
+
+//Timeline returns the sequence as a timeline: a single keyframe, held for one step, with every other frame as a
+//step of its transition.
+func (s Sequence) Timeline() timeline.Timeline {
+	if len(s.Frames) == 0 {
+		return timeline.Timeline{StepTime: s.StepTime}
+	}
+	return timeline.Timeline{
+		Keyframes:   []timeline.Keyframe{{Colors: s.Frames[0], Hold: s.StepTime}},
+		Transitions: []timeline.Transition{s.Frames[1:]},
+		StepTime:    s.StepTime,
+	}
+}
+
+//Validate checks that every frame has the same number of LEDs, and that the step time can be stored.
+func (s Sequence) Validate() error {
+	if s.StepTime < time.Millisecond || s.StepTime > 65535*time.Millisecond {
+		return fmt.Errorf("step time must be between 1ms and 65535ms, got: %v", s.StepTime)
+	}
+	for i, frame := range s.Frames {
+		if len(frame) != len(s.Frames[0]) {
+			return fmt.Errorf("frame %v has %v LEDs, but the first frame has %v", i, len(frame), len(s.Frames[0]))
+		}
+	}
+	return nil
+}
+
+//LedCount returns the number of LEDs in each frame.
+func (s Sequence) LedCount() int {
+	if len(s.Frames) == 0 {
+		return 0
+	}
+	return len(s.Frames[0])
+}
+
+//config holds the settings used when reading and writing files.
+type config struct {
+	version      int
+	compression  Compression
+	startChannel int
+}
+
+//Function used to set options
+type option func(*config)
+
+//Version provides an option for choosing the file version written, 1 or 2. Files are read in whatever version they are.
+//Version 2 is the default.
+func Version(major int) option {
+	return func(c *config) {
+		c.version = major
+	}
+}
+
+//Compress provides an option for compressing the frames of a version 2 file. Only None and Zlib can be written.
+func Compress(compression Compression) option {
+	return func(c *config) {
+		c.compression = compression
+	}
+}
+
+//StartChannel provides an option for setting the channel of the first LED's red, counting from 1 the way xLights does.
+//When writing, the channels before it are left dark. It is 1 by default.
+func StartChannel(channel int) option {
+	return func(c *config) {
+		c.startChannel = channel
+	}
+}
+
+//newConfig applies the options over the defaults.
+func newConfig(opts []option) (config, error) {
+	c := config{version: 2, compression: None, startChannel: 1}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.version != 1 && c.version != 2 {
+		return config{}, fmt.Errorf("fseq version must be 1 or 2, got: %v", c.version)
+	}
+	if c.compression != None && c.compression != Zlib {
+		return config{}, fmt.Errorf("only uncompressed and zlib compressed sequences can be written, got compression %v", c.compression)
+	}
+	if c.compression != None && c.version == 1 {
+		return config{}, fmt.Errorf("version 1 sequences can't be compressed")
+	}
+	if c.startChannel < 1 {
+		return config{}, fmt.Errorf("start channel must be at least 1, got: %v", c.startChannel)
+	}
+	return c, nil
+}
@@ -0,0 +1,176 @@
+package fseq
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"led-map/ledcolor"
+	"math"
+	"time"
+)
+
+//sparseRange is a run of channels stored in a version 2 file. Channels outside every range are dark.
+type sparseRange struct {
+	start int
+	count int
+}
+
+//Read reads an .fseq file, and returns the colors of ledCount LEDs in every frame.
+//The LEDs are read from StartChannel on, channels past the end of the file's frames are dark.
+//Example:
+//	file, err := os.Open("holiday.fseq")
+//	seq, err := fseq.Read(file, 100, fseq.StartChannel(301))
+func Read(in io.Reader, ledCount int, opts ...option) (Sequence, error) {
+	c, err := newConfig(opts)
+	if err != nil {
+		return Sequence{}, err
+	}
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return Sequence{}, err
+	}
+	if len(data) < v1HeaderLength || (string(data[:4]) != magic && string(data[:4]) != "FSEQ") {
+		return Sequence{}, fmt.Errorf("not an fseq file")
+	}
+	offset := int(binary.LittleEndian.Uint16(data[4:]))
+	major := data[7]
+	//The counts are kept unsigned until they are checked against the data, int is only 32 bits on a Pi
+	channelCount64 := uint64(binary.LittleEndian.Uint32(data[10:]))
+	frameCount64 := uint64(binary.LittleEndian.Uint32(data[14:]))
+	if offset > len(data) {
+		return Sequence{}, fmt.Errorf("channel data starts at %v, past the end of the file", offset)
+	}
+	if channelCount64 == 0 && frameCount64 > 0 {
+		return Sequence{}, fmt.Errorf("fseq file has %v frames, but no channels", frameCount64)
+	}
+
+	var stepTime time.Duration
+	var channels []byte
+	var ranges []sparseRange
+	switch major {
+	case 1:
+		stepTime = time.Duration(binary.LittleEndian.Uint16(data[18:])) * time.Millisecond
+		channels = data[offset:]
+	case 2:
+		stepTime = time.Duration(data[18]) * time.Millisecond
+		channels, ranges, err = readV2(data, offset, channelCount64*frameCount64)
+		if err != nil {
+			return Sequence{}, err
+		}
+	default:
+		return Sequence{}, fmt.Errorf("unsupported fseq version %v", major)
+	}
+	if uint64(len(channels)) < channelCount64*frameCount64 {
+		return Sequence{}, fmt.Errorf("fseq file has %v frames of %v channels, but only %v bytes of channel data", frameCount64, channelCount64, len(channels))
+	}
+	if frameCount64 == 0 {
+		return Sequence{StepTime: stepTime, Frames: make([][]int, 0)}, nil
+	}
+	//Both counts fit in an int now, as the data holds every frame
+	channelCount := int(channelCount64)
+	frameCount := int(frameCount64)
+
+	//Work out where each of the LEDs' channels is stored in a frame, or -1 if it isn't
+	positions := make([]int, ledCount*3)
+	for i := range positions {
+		positions[i] = position(c.startChannel-1+i, ranges, channelCount)
+	}
+	seq := Sequence{StepTime: stepTime, Frames: make([][]int, frameCount)}
+	rgb := make([]byte, 3)
+	for i := range seq.Frames {
+		frame := channels[i*channelCount : (i+1)*channelCount]
+		seq.Frames[i] = make([]int, ledCount)
+		for j := range seq.Frames[i] {
+			for k := range rgb {
+				rgb[k] = 0
+				if p := positions[j*3+k]; p >= 0 {
+					rgb[k] = frame[p]
+				}
+			}
+			seq.Frames[i][j] = ledcolor.Color{R: rgb[0], G: rgb[1], B: rgb[2]}.Grb()
+		}
+	}
+	return seq, nil
+}
+
+//readV2 reads the compression blocks and sparse ranges of a version 2 file, and returns its decompressed channel data.
+//No more than size bytes are decompressed, the size of every frame the header lists.
+func readV2(data []byte, offset int, size uint64) ([]byte, []sparseRange, error) {
+	if len(data) < v2HeaderLength {
+		return nil, nil, fmt.Errorf("fseq header is truncated")
+	}
+	compression := Compression(data[20] & 0x0f)
+	blockCount := int(data[21]) | int(data[20]&0xf0)<<4
+	rangeCount := int(data[22])
+	rangesStart := v2HeaderLength + blockCount*blockEntryLength
+	if rangesStart+rangeCount*rangeEntryLength > offset {
+		return nil, nil, fmt.Errorf("fseq header is truncated")
+	}
+
+	ranges := make([]sparseRange, rangeCount)
+	for i := range ranges {
+		entry := data[rangesStart+i*rangeEntryLength:]
+		ranges[i] = sparseRange{
+			start: int(entry[0]) | int(entry[1])<<8 | int(entry[2])<<16,
+			count: int(entry[3]) | int(entry[4])<<8 | int(entry[5])<<16,
+		}
+	}
+
+	switch compression {
+	case None:
+		return data[offset:], ranges, nil
+	case Zlib:
+	case Zstd:
+		return nil, nil, fmt.Errorf("zstd compressed fseq files aren't supported, export the sequence from xLights with zlib or no compression")
+	default:
+		return nil, nil, fmt.Errorf("unknown fseq compression %v", compression)
+	}
+
+	var channels bytes.Buffer
+	position := offset
+	for i := 0; i < blockCount; i++ {
+		length := int(binary.LittleEndian.Uint32(data[v2HeaderLength+i*blockEntryLength+4:]))
+		if length == 0 {
+			//xLights leaves unused block entries empty
+			continue
+		}
+		if position+length > len(data) {
+			return nil, nil, fmt.Errorf("compressed block %v runs past the end of the file", i)
+		}
+		r, err := zlib.NewReader(bytes.NewReader(data[position : position+length]))
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not decompress block %v: %v", i, err)
+		}
+		remaining := size - uint64(channels.Len())
+		if remaining > math.MaxInt64 {
+			remaining = math.MaxInt64
+		}
+		_, err = io.Copy(&channels, io.LimitReader(r, int64(remaining)))
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not decompress block %v: %v", i, err)
+		}
+		position += length
+	}
+	return channels.Bytes(), ranges, nil
+}
+
+//position returns where an absolute channel, counted from 0, is stored in a frame, or -1 if the frame doesn't store it.
+func position(channel int, ranges []sparseRange, channelCount int) int {
+	if len(ranges) == 0 {
+		if channel < channelCount {
+			return channel
+		}
+		return -1
+	}
+	stored := 0
+	for _, r := range ranges {
+		if channel >= r.start && channel < r.start+r.count {
+			return stored + channel - r.start
+		}
+		stored += r.count
+	}
+	return -1
+}
@@ -0,0 +1,101 @@
+package fseq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSequence(stepTime time.Duration) Sequence {
+	return Sequence{
+		StepTime: stepTime,
+		Frames: [][]int{
+			{0x102030, 0x405060},
+			{0x000000, 0xffffff},
+			{0x010203, 0x040506},
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		stepTime  time.Duration
+		writeOpts []option
+		readOpts  []option
+	}{
+		{"v2", DefaultStepTime, nil, nil},
+		{"v2 zlib", DefaultStepTime, []option{Compress(Zlib)}, nil},
+		{"v2 start channel", DefaultStepTime, []option{StartChannel(10)}, []option{StartChannel(10)}},
+		{"v1 slow", 500 * time.Millisecond, []option{Version(1)}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			seq := testSequence(test.stepTime)
+			var file bytes.Buffer
+			err := Write(&file, seq, test.writeOpts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Read(&file, seq.LedCount(), test.readOpts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, seq) {
+				t.Errorf("read %+v, want %+v", got, seq)
+			}
+		})
+	}
+}
+
+//header returns a version 1 header with the supplied counts and no channel data.
+func header(channelCount uint32, frameCount uint32) []byte {
+	data := make([]byte, v1HeaderLength)
+	copy(data, magic)
+	binary.LittleEndian.PutUint16(data[4:], v1HeaderLength)
+	data[7] = 1
+	binary.LittleEndian.PutUint16(data[8:], v1HeaderLength)
+	binary.LittleEndian.PutUint32(data[10:], channelCount)
+	binary.LittleEndian.PutUint32(data[14:], frameCount)
+	binary.LittleEndian.PutUint16(data[18:], 50)
+	return data
+}
+
+func TestReadCorruptHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"frames without channels", header(0, 0x7fffffff), "no channels"},
+		{"more frames than data", header(3, 0x7fffffff), "bytes of channel data"},
+		{"more channels than data", header(0xffffffff, 1), "bytes of channel data"},
+		{"counts overflowing", header(0xffffffff, 0xffffffff), "bytes of channel data"},
+		{"truncated frame", append(header(6, 2), make([]byte, 11)...), "bytes of channel data"},
+		{"truncated header", header(3, 1)[:20], "not an fseq file"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Read(bytes.NewReader(test.data), 2)
+			if err == nil {
+				t.Fatal("corrupt file was read without an error")
+			}
+			if !strings.Contains(err.Error(), test.want) {
+				t.Errorf("error %q should mention %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestReadEmpty(t *testing.T) {
+	seq, err := Read(bytes.NewReader(header(0, 0)), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seq.Frames) != 0 || seq.StepTime != DefaultStepTime {
+		t.Errorf("read %+v, want an empty sequence", seq)
+	}
+}
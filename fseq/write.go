@@ -0,0 +1,156 @@
+package fseq
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"led-map/ledcolor"
+	"time"
+)
+
+//Header layout
+const (
+	magic            = "PSEQ"
+	v1HeaderLength   = 28
+	v2HeaderLength   = 32
+	blockEntryLength = 8
+	rangeEntryLength = 6
+	maxBlocks        = 255
+	minBlockSize     = 64 * 1024 //channel bytes per compressed block, unless that would take more than maxBlocks
+	producer         = "led-map"
+)
+
+//Write writes the sequence to out as an .fseq file.
+//Example:
+//	file, err := os.Create("weather.fseq")
+//	err = fseq.Write(file, seq, fseq.Compress(fseq.Zlib), fseq.StartChannel(301))
+func Write(out io.Writer, seq Sequence, opts ...option) error {
+	c, err := newConfig(opts)
+	if err != nil {
+		return err
+	}
+	err = seq.Validate()
+	if err != nil {
+		return err
+	}
+	if c.version == 2 && seq.StepTime > MaxV2StepTime {
+		return fmt.Errorf("version 2 step times can't be longer than %v, got: %v, write version 1 instead", MaxV2StepTime, seq.StepTime)
+	}
+	channelCount := c.startChannel - 1 + seq.LedCount()*3
+	frames := make([][]byte, len(seq.Frames))
+	for i, colors := range seq.Frames {
+		frames[i] = make([]byte, channelCount)
+		for j, color := range colors {
+			copy(frames[i][c.startChannel-1+j*3:], ledcolor.RGB.Bytes(ledcolor.FromGrb(color)))
+		}
+	}
+	if c.version == 1 {
+		return writeV1(out, seq, channelCount, frames)
+	}
+	return writeV2(out, seq, channelCount, frames, c.compression)
+}
+
+//writeV1 writes a version 1 file, whose frames are always uncompressed.
+func writeV1(out io.Writer, seq Sequence, channelCount int, frames [][]byte) error {
+	variable := variableHeader("sp", producer)
+	offset := roundTo4(v1HeaderLength + len(variable))
+	header := make([]byte, offset)
+	copy(header, magic)
+	binary.LittleEndian.PutUint16(header[4:], uint16(offset))
+	header[6] = 0 //minor version
+	header[7] = 1 //major version
+	binary.LittleEndian.PutUint16(header[8:], v1HeaderLength)
+	binary.LittleEndian.PutUint32(header[10:], uint32(channelCount))
+	binary.LittleEndian.PutUint32(header[14:], uint32(len(frames)))
+	binary.LittleEndian.PutUint16(header[18:], uint16(seq.StepTime/time.Millisecond))
+	header[24] = 1 //gamma
+	header[25] = 2 //RGB color encoding
+	copy(header[v1HeaderLength:], variable)
+	return writeAll(out, header, frames)
+}
+
+//writeV2 writes a version 2 file. Compressed frames are split into blocks, so players can seek without
+//decompressing the whole sequence.
+func writeV2(out io.Writer, seq Sequence, channelCount int, frames [][]byte, compression Compression) error {
+	var blocks [][]byte
+	var firstFrames []int
+	if compression == Zlib {
+		perBlock := (len(frames) + maxBlocks - 1) / maxBlocks
+		if channelCount > 0 && perBlock*channelCount < minBlockSize {
+			perBlock = (minBlockSize + channelCount - 1) / channelCount
+		}
+		for first := 0; first < len(frames); first += perBlock {
+			last := first + perBlock
+			if last > len(frames) {
+				last = len(frames)
+			}
+			var block bytes.Buffer
+			w := zlib.NewWriter(&block)
+			for _, frame := range frames[first:last] {
+				w.Write(frame)
+			}
+			err := w.Close()
+			if err != nil {
+				return err
+			}
+			blocks = append(blocks, block.Bytes())
+			firstFrames = append(firstFrames, first)
+		}
+	}
+
+	variable := variableHeader("sp", producer)
+	variableOffset := v2HeaderLength + len(blocks)*blockEntryLength
+	offset := roundTo4(variableOffset + len(variable))
+	header := make([]byte, offset)
+	copy(header, magic)
+	binary.LittleEndian.PutUint16(header[4:], uint16(offset))
+	header[6] = 0 //minor version
+	header[7] = 2 //major version
+	binary.LittleEndian.PutUint16(header[8:], uint16(variableOffset))
+	binary.LittleEndian.PutUint32(header[10:], uint32(channelCount))
+	binary.LittleEndian.PutUint32(header[14:], uint32(len(frames)))
+	header[18] = byte(seq.StepTime / time.Millisecond)
+	header[20] = byte(compression)
+	header[21] = byte(len(blocks))
+	binary.LittleEndian.PutUint64(header[24:], uint64(time.Now().UnixNano()/int64(time.Microsecond)))
+	for i, block := range blocks {
+		entry := header[v2HeaderLength+i*blockEntryLength:]
+		binary.LittleEndian.PutUint32(entry, uint32(firstFrames[i]))
+		binary.LittleEndian.PutUint32(entry[4:], uint32(len(block)))
+	}
+	copy(header[variableOffset:], variable)
+	if compression == Zlib {
+		return writeAll(out, header, blocks)
+	}
+	return writeAll(out, header, frames)
+}
+
+//variableHeader encodes a variable length header: its length, its two letter code and a null terminated value.
+func variableHeader(code string, value string) []byte {
+	header := make([]byte, 4, 4+len(value)+1)
+	binary.LittleEndian.PutUint16(header, uint16(4+len(value)+1))
+	copy(header[2:], code)
+	header = append(header, value...)
+	return append(header, 0)
+}
+
+func roundTo4(n int) int {
+	return (n + 3) &^ 3
+}
+
+//writeAll writes the header followed by every chunk of channel data.
+func writeAll(out io.Writer, header []byte, chunks [][]byte) error {
+	_, err := out.Write(header)
+	if err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		_, err = out.Write(chunk)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+//Package ledcolor provides the color type used between the map and the LEDs, and the channel orders strips expect.
+//The rest of led-map passes colors around as ints packed the way utilities.HsvToGrb packs them.
+//Those ints are decoded into a Color at the edge of the pipeline, and only packed again in the order the
+//strip at the end of the wire wants, so the same colors render correctly on any strip.
+package ledcolor
+
+import (
+	"fmt"
+	"strings"
+)
+
+//Color is an LED color, independent of the order a strip expects its channels in.
+//W is only used by strips with a dedicated white LED.
+type Color struct {
+	R, G, B, W uint8
+}
+
+//FromGrb decodes a color packed the way utilities.HsvToGrb packs it.
+func FromGrb(grb int) Color {
+	return Color{
+		G: uint8(grb >> 16),
+		R: uint8(grb >> 8),
+		B: uint8(grb),
+	}
+}
+
+//Grb packs a color the way utilities.HsvToGrb does. The white channel is dropped.
+func (c Color) Grb() int {
+	return int(c.G)<<16 + int(c.R)<<8 + int(c.B)
+}
+
+//ExtractWhite moves the part of the color that is common to red, green and blue into the white channel.
+//Near-white colors are then shown mostly by the dedicated white LED, which looks much cleaner than mixing
+//red, green and blue, and draws less current. Colors without any white in them are left untouched.
+//Example:
+//	Color{R: 255, G: 255, B: 200}.ExtractWhite() //Color{R: 55, G: 55, B: 0, W: 200}
+func (c Color) ExtractWhite() Color {
+	white := c.R
+	if c.G < white {
+		white = c.G
+	}
+	if c.B < white {
+		white = c.B
+	}
+	c.R -= white
+	c.G -= white
+	c.B -= white
+	if int(c.W)+int(white) > 255 {
+		c.W = 255
+	} else {
+		c.W += white
+	}
+	return c
+}
+
+//channel returns the value of one channel, named by its letter.
+func (c Color) channel(letter byte) uint8 {
+	switch letter {
+	case 'R':
+		return c.R
+	case 'G':
+		return c.G
+	case 'B':
+		return c.B
+	default:
+		return c.W
+	}
+}
+
+//Order is the order a strip expects its color channels in, written as the channel letters in the order they are sent.
+type Order string
+
+//3 channel orders
+const (
+	RGB Order = "RGB"
+	RBG Order = "RBG"
+	GRB Order = "GRB"
+	GBR Order = "GBR"
+	BRG Order = "BRG"
+	BGR Order = "BGR"
+)
+
+//4 channel orders, for strips with a white LED
+const (
+	RGBW Order = "RGBW"
+	RBGW Order = "RBGW"
+	GRBW Order = "GRBW"
+	GBRW Order = "GBRW"
+	BRGW Order = "BRGW"
+	BGRW Order = "BGRW"
+)
+
+//Orders of common LED chips
+const (
+	WS2811  = RGB
+	WS2812  = GRB
+	SK6812  = GRB
+	SK6812W = GRBW
+	APA102  = BGR
+)
+
+var chipOrders = map[string]Order{
+	"WS2811":  WS2811,
+	"WS2812":  WS2812,
+	"WS2812B": WS2812,
+	"SK6812":  SK6812,
+	"SK6812W": SK6812W,
+	"APA102":  APA102,
+	"SK9822":  APA102,
+}
+
+//ParseOrder converts an order such as "GRBW", or a chip such as "WS2812B", into an Order.
+func ParseOrder(name string) (Order, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	order, ok := chipOrders[name]
+	if !ok {
+		order = Order(name)
+	}
+	err := order.Validate()
+	if err != nil {
+		return "", err
+	}
+	return order, nil
+}
+
+//Validate checks that the order names R, G and B exactly once each, optionally followed by W.
+func (o Order) Validate() error {
+	if len(o) != 3 && !(len(o) == 4 && o[3] == 'W') {
+		return fmt.Errorf("unknown channel order %q, expected something like GRB or GRBW", string(o))
+	}
+	seen := make(map[rune]bool)
+	for _, letter := range o[:3] {
+		if seen[letter] || !strings.ContainsRune("RGB", letter) {
+			return fmt.Errorf("unknown channel order %q, expected something like GRB or GRBW", string(o))
+		}
+		seen[letter] = true
+	}
+	return nil
+}
+
+//Channels returns the number of channels in the order, 3 or 4.
+func (o Order) Channels() int {
+	return len(o)
+}
+
+//HasWhite reports whether the order includes a white channel.
+func (o Order) HasWhite() bool {
+	return len(o) == 4
+}
+
+//Bytes returns the color's channels in the order they are sent to the strip.
+func (o Order) Bytes(c Color) []byte {
+	bytes := make([]byte, len(o))
+	for i := 0; i < len(o); i++ {
+		bytes[i] = c.channel(o[i])
+	}
+	return bytes
+}
+
+//Pack packs the color into a single word in the order it is sent to the strip.
+//The first three channels are in bits 16-23, 8-15 and 0-7, and a fourth channel is in bits 24-31.
+//This is the layout the ws2811 driver sends unchanged when it is set to an RGB or RGBW strip.
+func (o Order) Pack(c Color) uint32 {
+	bytes := o.Bytes(c)
+	packed := uint32(bytes[0])<<16 | uint32(bytes[1])<<8 | uint32(bytes[2])
+	if len(bytes) == 4 {
+		packed |= uint32(bytes[3]) << 24
+	}
+	return packed
+}
+
+//UnmarshalText lets an Order be written as an order or a chip name in a config file.
+func (o *Order) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*o = ""
+		return nil
+	}
+	order, err := ParseOrder(string(text))
+	if err != nil {
+		return err
+	}
+	*o = order
+	return nil
+}
@@ -0,0 +1,123 @@
+package ledcolor
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGrb(t *testing.T) {
+	c := FromGrb(0x112233)
+	if c != (Color{G: 0x11, R: 0x22, B: 0x33}) {
+		t.Errorf("FromGrb(0x112233) = %+v", c)
+	}
+	if grb := (Color{R: 0x22, G: 0x11, B: 0x33, W: 0x44}).Grb(); grb != 0x112233 {
+		t.Errorf("Grb() = %x, want 112233", grb)
+	}
+}
+
+func TestPack(t *testing.T) {
+	c := Color{R: 0x11, G: 0x22, B: 0x33, W: 0x44}
+	tests := []struct {
+		order Order
+		bytes []byte
+		want  uint32
+	}{
+		{RGB, []byte{0x11, 0x22, 0x33}, 0x112233},
+		{RBG, []byte{0x11, 0x33, 0x22}, 0x113322},
+		{GRB, []byte{0x22, 0x11, 0x33}, 0x221133},
+		{GBR, []byte{0x22, 0x33, 0x11}, 0x223311},
+		{BRG, []byte{0x33, 0x11, 0x22}, 0x331122},
+		{BGR, []byte{0x33, 0x22, 0x11}, 0x332211},
+		//White always goes in the top byte
+		{RGBW, []byte{0x11, 0x22, 0x33, 0x44}, 0x44112233},
+		{GRBW, []byte{0x22, 0x11, 0x33, 0x44}, 0x44221133},
+		{BGRW, []byte{0x33, 0x22, 0x11, 0x44}, 0x44332211},
+	}
+	for _, test := range tests {
+		bytes := test.order.Bytes(c)
+		if string(bytes) != string(test.bytes) {
+			t.Errorf("%v.Bytes() = %x, want %x", test.order, bytes, test.bytes)
+		}
+		if packed := test.order.Pack(c); packed != test.want {
+			t.Errorf("%v.Pack() = %08x, want %08x", test.order, packed, test.want)
+		}
+		if channels := test.order.Channels(); channels != len(test.bytes) {
+			t.Errorf("%v has %v channels, want %v", test.order, channels, len(test.bytes))
+		}
+		if test.order.HasWhite() != (len(test.bytes) == 4) {
+			t.Errorf("%v.HasWhite() = %v", test.order, test.order.HasWhite())
+		}
+	}
+}
+
+func TestParseOrder(t *testing.T) {
+	valid := []struct {
+		name string
+		want Order
+	}{
+		{"GRB", GRB},
+		{"grbw", GRBW},
+		{" BGR ", BGR},
+		{"WS2811", RGB},
+		{"ws2812b", GRB},
+		{"SK6812W", GRBW},
+		{"APA102", BGR},
+		{"SK9822", BGR},
+	}
+	for _, test := range valid {
+		order, err := ParseOrder(test.name)
+		if err != nil {
+			t.Errorf("ParseOrder(%q) failed: %v", test.name, err)
+			continue
+		}
+		if order != test.want {
+			t.Errorf("ParseOrder(%q) = %v, want %v", test.name, order, test.want)
+		}
+	}
+	invalid := []string{"", "RG", "RGGB", "RRG", "GRBX", "WRGB", "RGBWW", "XYZ", "WS2813"}
+	for _, name := range invalid {
+		if _, err := ParseOrder(name); err == nil {
+			t.Errorf("ParseOrder(%q) was accepted", name)
+		}
+		if err := Order(name).Validate(); err == nil {
+			t.Errorf("Order(%q).Validate() was accepted", name)
+		}
+	}
+}
+
+func TestUnmarshalOrder(t *testing.T) {
+	var channel struct {
+		Order Order `json:"order"`
+	}
+	err := json.Unmarshal([]byte(`{"order": "ws2812b"}`), &channel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if channel.Order != GRB {
+		t.Errorf("read order %v, want GRB", channel.Order)
+	}
+	err = json.Unmarshal([]byte(`{"order": "GRX"}`), &channel)
+	if err == nil {
+		t.Error("invalid order was read")
+	}
+}
+
+func TestExtractWhite(t *testing.T) {
+	tests := []struct {
+		color Color
+		want  Color
+	}{
+		{Color{R: 255, G: 255, B: 200}, Color{R: 55, G: 55, B: 0, W: 200}},
+		{Color{R: 255, G: 255, B: 255}, Color{W: 255}},
+		{Color{R: 255, G: 0, B: 128}, Color{R: 255, G: 0, B: 128}},
+		{Color{}, Color{}},
+		//White that is already set is added to, up to full brightness
+		{Color{R: 10, G: 20, B: 30, W: 5}, Color{R: 0, G: 10, B: 20, W: 15}},
+		{Color{R: 100, G: 100, B: 100, W: 200}, Color{W: 255}},
+	}
+	for _, test := range tests {
+		if got := test.color.ExtractWhite(); got != test.want {
+			t.Errorf("%+v.ExtractWhite() = %+v, want %+v", test.color, got, test.want)
+		}
+	}
+}
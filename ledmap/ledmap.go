@@ -2,10 +2,13 @@ package ledmap
 
 import (
 	"led-map/ledstrip"
+	"led-map/timeline"
+	"sync"
+	"time"
 )
 
-//MapController is any function that takes a list of color values and uses them to set the map's LED colors.
-type MapController func([][][]int, ColorFiller) error
+//MapController is any function that takes a timeline and uses it to set the map's LED colors.
+type MapController func(timeline.Timeline, ColorFiller) error
 
 //ColorFiller is something that mimics the behavior of an LED strip
 type ColorFiller interface {
@@ -21,22 +24,32 @@ type option func(*LedMap)
 //LedMap represents
 type LedMap struct {
 	leds       ColorFiller
-	colors     [][][]int
+	colors     timeline.Timeline
 	controller MapController
+	stop       chan struct{} //closed by Stop
+	stopOnce   sync.Once
+	mu         sync.Mutex
+	shown      []int //last frame filled, for FadeTo
 }
 
 //New creates a new LedMap and returns it.
-func New() (*LedMap, error) {
-	stripLength := 100
-	strip, err := ledstrip.Init(stripLength, 255, false)
-	if err != nil {
-		return &LedMap{}, err
-	}
-	colors := make([][][]int, 0)
+//Any options are applied before the map is returned. If none of them sets the LEDs,
+//a 100 LED strip is initialized with ledstrip.Init.
+func New(opts ...option) (*LedMap, error) {
 	l := &LedMap{
-		leds:       strip,
-		colors:     colors,
+		leds:       nil,
+		colors:     timeline.Timeline{},
 		controller: nil,
+		stop:       make(chan struct{}),
+	}
+	l.Option(opts...)
+	if l.leds == nil {
+		stripLength := 100
+		strip, err := ledstrip.Init(stripLength, 255, false)
+		if err != nil {
+			return &LedMap{}, err
+		}
+		l.leds = strip
 	}
 	return l, nil
 }
@@ -55,8 +68,8 @@ func LEDs(leds ColorFiller) option {
 	}
 }
 
-//Colors provides an option for setting the array of colors on the LedMap.
-func Colors(colors [][][]int) option {
+//Colors provides an option for setting the timeline RunMapController runs the controller on.
+func Colors(colors timeline.Timeline) option {
 	return func(l *LedMap) {
 		l.colors = colors
 	}
@@ -69,15 +82,85 @@ func Controller(controller MapController) option {
 	}
 }
 
-//RunMapController runs the LedMap's controller on the LedMap's colors.
+//StartMap runs through one cycle of the supplied timeline.
+//Each keyframe is held for its hold, then the map fades to the next keyframe through the transition after it.
+//Transition steps are rendered as fast as the LEDs allow, whatever the timeline's step time is. Wrap the LEDs in a
+//Scheduler to fade at a fixed frame rate.
+//If the map is stopped, StartMap returns after the frame it is on, or straight away if it is holding a keyframe.
+func (l *LedMap) StartMap(colors timeline.Timeline) {
+	for i, keyframe := range colors.Keyframes {
+		if l.Stopped() {
+			return
+		}
+		//Set the keyframe, then wait the prescribed amount of time
+		l.show(keyframe.Colors)
+		select {
+		case <-l.stop:
+			return
+		case <-time.After(keyframe.Hold):
+		}
+		for _, step := range colors.Transition(i) {
+			if l.Stopped() {
+				return
+			}
+			l.show(step)
+			//Pacing is up to the LEDs, a Scheduler holds each step for one frame
+		}
+	}
+}
+
+//show fills and renders one frame, and remembers it for FadeTo.
+func (l *LedMap) show(frame []int) {
+	l.leds.Fill(frame)
+	l.leds.Render()
+	l.remember(frame)
+}
+
+//remember keeps a copy of the last frame filled.
+func (l *LedMap) remember(frame []int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.shown) != len(frame) {
+		l.shown = make([]int, len(frame))
+	}
+	copy(l.shown, frame)
+}
+
+//RunMapController runs the LedMap's controller on the LedMap's timeline, once it has been validated.
+//Once the map is stopped, the controller's LEDs return ErrStopped, so it returns at a frame boundary with that error.
 func (l *LedMap) RunMapController() error {
-	err := l.controller(l.colors, l.leds)
+	err := l.colors.Validate()
+	if err != nil {
+		return err
+	}
+	err = l.controller(l.colors, stoppable{l})
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+//Timed is a MapController that shows every frame of the timeline at its time, holds and steps alike.
+//Frames are timed from the start, so slow renders don't make the timeline drift.
+//Example:
+//	weathermap, err := ledmap.New(ledmap.LEDs(strip), ledmap.Colors(forecast), ledmap.Controller(ledmap.Timed))
+//	err = weathermap.RunMapController()
+func Timed(colors timeline.Timeline, leds ColorFiller) error {
+	start := time.Now()
+	for _, frame := range colors.Frames() {
+		time.Sleep(time.Until(start.Add(frame.At)))
+		err := leds.Fill(frame.Colors)
+		if err != nil {
+			return err
+		}
+		err = leds.Render()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // for i, colorStrandSet := range colors {
 // 	//Set initial color in group, then wait the prescribed amount of time
 // 	l.leds.Fill(colors[i][0])
@@ -0,0 +1,153 @@
+package ledmap
+
+import (
+	"led-map/timeline"
+	"led-map/virtualstrip"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+//testTimeline returns a two keyframe timeline of two LEDs, with a two step transition between them.
+func testTimeline(hold time.Duration, stepTime time.Duration) timeline.Timeline {
+	return timeline.Timeline{
+		Keyframes: []timeline.Keyframe{
+			{Colors: []int{0x000000, 0x0000ff}, Hold: hold},
+			{Colors: []int{0x00ff00, 0xff0000}, Hold: hold},
+		},
+		Transitions: []timeline.Transition{
+			{{0x005500, 0x550055}, {0x00aa00, 0xaa00aa}},
+		},
+		StepTime: stepTime,
+	}
+}
+
+//tickingClock returns a clock for virtualstrip.Clock that starts at start and moves on by step every time it is read.
+func tickingClock(start time.Time, step time.Duration) func() time.Time {
+	var mu sync.Mutex
+	now := start
+	return func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		current := now
+		now = now.Add(step)
+		return current
+	}
+}
+
+func TestStartMap(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	strip := virtualstrip.New(2, false, virtualstrip.Clock(tickingClock(start, time.Second)))
+	l, err := New(LEDs(strip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	colors := testTimeline(time.Millisecond, 0)
+	l.StartMap(colors)
+
+	frames := strip.Frames()
+	want := colors.Frames()
+	if len(frames) != len(want) {
+		t.Fatalf("StartMap rendered %v frames, want %v", len(frames), len(want))
+	}
+	for i, frame := range frames {
+		if !reflect.DeepEqual(frame.Colors, want[i].Colors) {
+			t.Errorf("frame %v = %x, want %x", i, frame.Colors, want[i].Colors)
+		}
+		//Every frame is rendered once, so the clock is read once per frame
+		if at := start.Add(time.Duration(i) * time.Second); !frame.Time.Equal(at) {
+			t.Errorf("frame %v rendered at %v, want %v", i, frame.Time, at)
+		}
+	}
+}
+
+func TestStartMapHolds(t *testing.T) {
+	strip := virtualstrip.New(2, false)
+	l, err := New(LEDs(strip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hold := 30 * time.Millisecond
+	l.StartMap(testTimeline(hold, 0))
+
+	frames := strip.Frames()
+	if len(frames) != 4 {
+		t.Fatalf("StartMap rendered %v frames, want 4", len(frames))
+	}
+	//The first keyframe is held before the transition, the second one before StartMap returns
+	if gap := frames[1].Time.Sub(frames[0].Time); gap < hold {
+		t.Errorf("first keyframe held for %v, want at least %v", gap, hold)
+	}
+}
+
+func TestStartMapStopped(t *testing.T) {
+	strip := virtualstrip.New(2, false)
+	l, err := New(LEDs(strip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		l.Stop()
+	}()
+	done := make(chan struct{})
+	go func() {
+		l.StartMap(testTimeline(time.Hour, 0))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("StartMap kept holding a keyframe after Stop")
+	}
+	if count := strip.FrameCount(); count != 1 {
+		t.Errorf("StartMap rendered %v frames before it was stopped, want 1", count)
+	}
+}
+
+func TestRunMapControllerTimed(t *testing.T) {
+	strip := virtualstrip.New(2, false)
+	colors := testTimeline(20*time.Millisecond, 10*time.Millisecond)
+	l, err := New(LEDs(strip), Colors(colors), Controller(Timed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.RunMapController()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames := strip.Frames()
+	want := colors.Frames()
+	if len(frames) != len(want) {
+		t.Fatalf("Timed rendered %v frames, want %v", len(frames), len(want))
+	}
+	for i, frame := range frames {
+		if !reflect.DeepEqual(frame.Colors, want[i].Colors) {
+			t.Errorf("frame %v = %x, want %x", i, frame.Colors, want[i].Colors)
+		}
+		//Frames are never shown early
+		if at := frame.Time.Sub(frames[0].Time); at < want[i].At {
+			t.Errorf("frame %v rendered %v after the first, want at least %v", i, at, want[i].At)
+		}
+	}
+}
+
+func TestRunMapControllerInvalidTimeline(t *testing.T) {
+	strip := virtualstrip.New(2, false)
+	called := false
+	l, err := New(LEDs(strip), Colors(timeline.Timeline{}), Controller(func(timeline.Timeline, ColorFiller) error {
+		called = true
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.RunMapController() == nil {
+		t.Error("RunMapController accepted an empty timeline")
+	}
+	if called {
+		t.Error("controller ran on an invalid timeline")
+	}
+}
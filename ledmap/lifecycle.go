@@ -0,0 +1,158 @@
+package ledmap
+
+import (
+	"errors"
+	"led-map/ledcolor"
+	"math"
+	"time"
+)
+
+//ErrStopped is returned by the LEDs a MapController is given, once the map has been stopped.
+var ErrStopped = errors.New("the map has been stopped")
+
+//fadeInterval is how often Fade renders a step.
+const fadeInterval = 20 * time.Millisecond
+
+//Stop makes StartMap and RunMapController return at the next frame boundary. It is safe to call from any
+//goroutine, such as a signal handler, and more than once.
+func (l *LedMap) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+}
+
+//Stopped reports whether Stop has been called.
+func (l *LedMap) Stopped() bool {
+	select {
+	case <-l.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+//FadeTo fades every LED from the last frame the map showed to a single color over the supplied duration.
+//It also works once the map has been stopped, which makes it the way to take the map down gracefully.
+//Example:
+//	weathermap.Stop()
+//	err := weathermap.FadeTo(0, time.Second) //fade to black
+func (l *LedMap) FadeTo(color int, duration time.Duration) error {
+	l.mu.Lock()
+	from := make([]int, len(l.shown))
+	copy(from, l.shown)
+	l.mu.Unlock()
+	err := Fade(l.leds, from, color, duration)
+	if err != nil {
+		return err
+	}
+	for i := range from {
+		from[i] = color
+	}
+	l.remember(from)
+	return nil
+}
+
+//Fade fades the LEDs from a frame to a single color over the supplied duration, rendering a step every 20ms.
+//If from is empty or the duration isn't positive, the color is shown straight away.
+//Example:
+//	err := ledmap.Fade(strip, lastFrame, 0, time.Second) //fade to black
+func Fade(leds ColorFiller, from []int, color int, duration time.Duration) error {
+	if len(from) == 0 || duration <= 0 {
+		err := leds.FillSingle(color)
+		if err != nil {
+			return err
+		}
+		return leds.Render()
+	}
+
+	to := ledcolor.FromGrb(color)
+	frame := make([]int, len(from))
+	start := time.Now()
+	for {
+		progress := math.Min(1, float64(time.Since(start))/float64(duration))
+		for i, c := range from {
+			frame[i] = blend(ledcolor.FromGrb(c), to, progress).Grb()
+		}
+		err := leds.Fill(frame)
+		if err != nil {
+			return err
+		}
+		err = leds.Render()
+		if err != nil {
+			return err
+		}
+		if progress >= 1 {
+			return nil
+		}
+		time.Sleep(fadeInterval)
+	}
+}
+
+//blend mixes two colors. Progress 0 is all from, and 1 is all to.
+func blend(from ledcolor.Color, to ledcolor.Color, progress float64) ledcolor.Color {
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*progress))
+	}
+	return ledcolor.Color{R: mix(from.R, to.R), G: mix(from.G, to.G), B: mix(from.B, to.B)}
+}
+
+//stoppable is the ColorFiller a MapController draws on. It remembers every frame for FadeTo,
+//and refuses to draw anything once the map has been stopped.
+type stoppable struct {
+	l *LedMap
+}
+
+//FillSingle applies a color to all LEDs.
+func (s stoppable) FillSingle(color int) error {
+	if s.l.Stopped() {
+		return ErrStopped
+	}
+	err := s.l.leds.FillSingle(color)
+	if err != nil {
+		return err
+	}
+	s.l.mu.Lock()
+	defer s.l.mu.Unlock()
+	for i := range s.l.shown {
+		s.l.shown[i] = color
+	}
+	return nil
+}
+
+//Fill applies an array of colors to all LEDs.
+func (s stoppable) Fill(colors []int) error {
+	if s.l.Stopped() {
+		return ErrStopped
+	}
+	err := s.l.leds.Fill(colors)
+	if err != nil {
+		return err
+	}
+	s.l.remember(colors)
+	return nil
+}
+
+//Set sets a single LED's color.
+func (s stoppable) Set(index int, color int) error {
+	if s.l.Stopped() {
+		return ErrStopped
+	}
+	err := s.l.leds.Set(index, color)
+	if err != nil {
+		return err
+	}
+	s.l.mu.Lock()
+	defer s.l.mu.Unlock()
+	if index < len(s.l.shown) {
+		s.l.shown[index] = color
+	}
+	return nil
+}
+
+//Render renders the LEDs.
+func (s stoppable) Render() error {
+	if s.l.Stopped() {
+		return ErrStopped
+	}
+	return s.l.leds.Render()
+}
@@ -0,0 +1,125 @@
+package ledmap
+
+import (
+	"led-map/timeline"
+	"led-map/virtualstrip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//oneFrame returns a timeline that holds a single frame, for controllers that draw their own frames.
+func oneFrame(colors []int) timeline.Timeline {
+	return timeline.Timeline{Keyframes: []timeline.Keyframe{{Colors: colors}}}
+}
+
+func TestStop(t *testing.T) {
+	l, err := New(LEDs(virtualstrip.New(3, false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Stopped() {
+		t.Fatal("new map is stopped")
+	}
+	l.Stop()
+	l.Stop()
+	if !l.Stopped() {
+		t.Fatal("map isn't stopped after Stop")
+	}
+}
+
+func TestStoppedControllerGetsErrStopped(t *testing.T) {
+	strip := virtualstrip.New(3, false)
+	frame := []int{0x010000, 0x000100, 0x000001}
+	var drawn, refused []error
+	var l *LedMap
+	controller := func(colors timeline.Timeline, leds ColorFiller) error {
+		drawn = []error{leds.Fill(frame), leds.Render()}
+		l.Stop()
+		refused = []error{leds.FillSingle(0), leds.Fill(frame), leds.Set(0, 0), leds.Render()}
+		return refused[0]
+	}
+	l, err := New(LEDs(strip), Colors(oneFrame(frame)), Controller(controller))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.RunMapController()
+	if err != ErrStopped {
+		t.Errorf("RunMapController returned %v, want ErrStopped", err)
+	}
+	for i, err := range drawn {
+		if err != nil {
+			t.Errorf("call %v before Stop returned %v", i, err)
+		}
+	}
+	for i, err := range refused {
+		if err != ErrStopped {
+			t.Errorf("call %v after Stop returned %v, want ErrStopped", i, err)
+		}
+	}
+	if strip.FrameCount() != 1 || !reflect.DeepEqual(strip.Rendered(), frame) {
+		t.Errorf("strip rendered %v frames ending in %x, want 1 frame of %x", strip.FrameCount(), strip.Rendered(), frame)
+	}
+}
+
+func TestFadeTo(t *testing.T) {
+	strip := virtualstrip.New(2, false)
+	frame := []int{0xc8c8c8, 0x640000}
+	l, err := New(LEDs(strip), Colors(oneFrame(frame)), Controller(func(colors timeline.Timeline, leds ColorFiller) error {
+		err := leds.Fill(frame)
+		if err != nil {
+			return err
+		}
+		return leds.Render()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.RunMapController()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Stop()
+	strip.Reset()
+
+	err = l.FadeTo(0, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames := strip.Frames()
+	if len(frames) < 3 {
+		t.Fatalf("fade rendered %v frames, want several", len(frames))
+	}
+	if last := frames[len(frames)-1].Colors; !reflect.DeepEqual(last, []int{0, 0}) {
+		t.Errorf("fade ended on %x, want black", last)
+	}
+	for i := 1; i < len(frames); i++ {
+		for led := range frame {
+			if frames[i].Colors[led] > frames[i-1].Colors[led] {
+				t.Fatalf("LED %v got brighter in step %v of a fade to black: %x after %x", led, i, frames[i].Colors[led], frames[i-1].Colors[led])
+			}
+		}
+	}
+
+	//The next fade starts from where the last one ended
+	strip.Reset()
+	err = l.FadeTo(0x0a0a0a, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames := strip.Frames(); len(frames) != 1 || !reflect.DeepEqual(frames[0].Colors, []int{0x0a0a0a, 0x0a0a0a}) {
+		t.Errorf("fade without a duration rendered %v", frames)
+	}
+}
+
+func TestFadeWithoutFrame(t *testing.T) {
+	strip := virtualstrip.New(2, false)
+	err := Fade(strip, nil, 0x102030, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames := strip.Frames(); len(frames) != 1 || !reflect.DeepEqual(frames[0].Colors, []int{0x102030, 0x102030}) {
+		t.Errorf("fade without a frame to start from rendered %v, want the color straight away", frames)
+	}
+}
@@ -0,0 +1,165 @@
+package ledmap
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//FrameStats reports how well a Scheduler has kept to its frame rate.
+type FrameStats struct {
+	Frames         uint64        //frames rendered
+	Skipped        uint64        //renders skipped because the frame hadn't changed
+	Late           uint64        //frames that took longer to render than one frame
+	Dropped        uint64        //frame slots lost to late frames
+	LastLatency    time.Duration //how long the last render took
+	AverageLatency time.Duration
+	MaxLatency     time.Duration
+}
+
+//Scheduler is a ColorFiller decorator that renders at a fixed frame rate.
+//Every Render waits for the start of its frame slot, so a fade takes just as long on a Pi Zero as on a Pi 4,
+//as long as the LEDs can render within one frame. Renders of a frame that hasn't changed still take their slot,
+//but aren't passed on to the LEDs.
+//
+//Time spent between renders, such as the pauses StartMap holds forecasts for, restarts the schedule instead of
+//counting as late. Only renders that overrun their own slot are late.
+type Scheduler struct {
+	leds     ColorFiller
+	interval time.Duration
+
+	mu       sync.Mutex
+	pending  []int
+	rendered []int
+	fresh    bool //nothing has been rendered yet, so the first frame always is
+	next     time.Time
+	stats    FrameStats
+	total    time.Duration //latency of every render, for the average
+}
+
+//MaxFrameRate is the fastest frame rate a Scheduler accepts. No strip refreshes anywhere near this fast,
+//and it keeps the interval between frames well above zero.
+const MaxFrameRate = 1000
+
+//NewScheduler wraps leds, which must have ledCount LEDs, in a Scheduler that renders fps frames per second.
+//Example:
+//	paced, err := ledmap.NewScheduler(strip, 100, 60)
+//	weathermap, err := ledmap.New(ledmap.LEDs(paced))
+func NewScheduler(leds ColorFiller, ledCount int, fps int) (*Scheduler, error) {
+	if fps <= 0 || fps > MaxFrameRate {
+		return &Scheduler{}, fmt.Errorf("frame rate must be between 1 and %v frames per second, got: %v", MaxFrameRate, fps)
+	}
+	return &Scheduler{
+		leds:     leds,
+		interval: time.Second / time.Duration(fps),
+		pending:  make([]int, ledCount),
+		rendered: make([]int, ledCount),
+		fresh:    true,
+	}, nil
+}
+
+//FillSingle applies a color to all LEDs.
+func (s *Scheduler) FillSingle(color int) error {
+	err := s.leds.FillSingle(color)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.pending {
+		s.pending[i] = color
+	}
+	return nil
+}
+
+//Fill applies an array of colors to all LEDs.
+func (s *Scheduler) Fill(colors []int) error {
+	err := s.leds.Fill(colors)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copy(s.pending, colors)
+	return nil
+}
+
+//Set sets a single LED's color.
+func (s *Scheduler) Set(index int, color int) error {
+	err := s.leds.Set(index, color)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index < len(s.pending) {
+		s.pending[index] = color
+	}
+	return nil
+}
+
+//Render waits for the next frame slot, then renders the wrapped LEDs if the frame has changed since the last render.
+func (s *Scheduler) Render() error {
+	s.mu.Lock()
+	now := time.Now()
+	if now.After(s.next) {
+		s.next = now
+	}
+	slot := s.next
+	s.mu.Unlock()
+	time.Sleep(time.Until(slot))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.fresh && !s.changed() {
+		s.stats.Skipped++
+		s.next = slot.Add(s.interval)
+		return nil
+	}
+	start := time.Now()
+	err := s.leds.Render()
+	end := time.Now()
+	if err != nil {
+		s.next = end
+		return err
+	}
+	copy(s.rendered, s.pending)
+	s.fresh = false
+
+	latency := end.Sub(start)
+	s.total += latency
+	s.stats.Frames++
+	s.stats.LastLatency = latency
+	s.stats.AverageLatency = s.total / time.Duration(s.stats.Frames)
+	if latency > s.stats.MaxLatency {
+		s.stats.MaxLatency = latency
+	}
+	//A frame that overruns its slot takes up every slot it ran into
+	slots := int64((end.Sub(slot) + s.interval - 1) / s.interval)
+	if slots < 1 {
+		slots = 1
+	}
+	if slots > 1 {
+		s.stats.Late++
+		s.stats.Dropped += uint64(slots - 1)
+	}
+	s.next = slot.Add(time.Duration(slots) * s.interval)
+	return nil
+}
+
+//changed reports whether the pending frame differs from the last rendered one. The caller must hold the lock.
+func (s *Scheduler) changed() bool {
+	for i, color := range s.pending {
+		if color != s.rendered[i] {
+			return true
+		}
+	}
+	return false
+}
+
+//Stats returns the frame statistics so far.
+func (s *Scheduler) Stats() FrameStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
@@ -0,0 +1,166 @@
+package ledmap
+
+import (
+	"led-map/virtualstrip"
+	"testing"
+	"time"
+)
+
+//slowStrip is a virtual strip that takes a while to render.
+type slowStrip struct {
+	*virtualstrip.VirtualStrip
+	delay time.Duration
+}
+
+func (s *slowStrip) Render() error {
+	time.Sleep(s.delay)
+	return s.VirtualStrip.Render()
+}
+
+func TestSchedulerFrameRate(t *testing.T) {
+	for _, fps := range []int{-1, 0, MaxFrameRate + 1} {
+		_, err := NewScheduler(virtualstrip.New(1, false), 1, fps)
+		if err == nil {
+			t.Errorf("frame rate %v was accepted", fps)
+		}
+	}
+	for _, fps := range []int{1, MaxFrameRate} {
+		_, err := NewScheduler(virtualstrip.New(1, false), 1, fps)
+		if err != nil {
+			t.Errorf("frame rate %v was rejected: %v", fps, err)
+		}
+	}
+}
+
+func TestSchedulerPacing(t *testing.T) {
+	strip := virtualstrip.New(1, false)
+	s, err := NewScheduler(strip, 1, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interval := 20 * time.Millisecond
+	for i := 0; i < 5; i++ {
+		err = s.Fill([]int{i + 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	frames := strip.Frames()
+	if len(frames) != 5 {
+		t.Fatalf("rendered %v frames, want 5", len(frames))
+	}
+	//The first frame starts the schedule, and every later one waits for its own slot
+	if took := frames[4].Time.Sub(frames[0].Time); took < 4*interval-2*time.Millisecond {
+		t.Errorf("5 frames at 50fps took %v, want at least %v", took, 4*interval)
+	}
+	stats := s.Stats()
+	if stats.Frames != 5 || stats.Skipped != 0 || stats.Late != 0 || stats.Dropped != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestSchedulerSkipsUnchangedFrames(t *testing.T) {
+	strip := virtualstrip.New(2, false)
+	s, err := NewScheduler(strip, 2, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interval := 10 * time.Millisecond
+
+	//The first frame is rendered even though it is the same as the dark strip
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		err = s.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = s.Set(1, 0xff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count := strip.FrameCount(); count != 2 {
+		t.Errorf("strip rendered %v frames, want 2", count)
+	}
+	stats := s.Stats()
+	if stats.Frames != 2 || stats.Skipped != 2 {
+		t.Errorf("stats = %+v, want 2 frames and 2 skipped", stats)
+	}
+	//Skipped frames still take their slot
+	if took := time.Since(start); took < 3*interval {
+		t.Errorf("4 renders took %v, want at least %v", took, 3*interval)
+	}
+}
+
+func TestSchedulerLateFrames(t *testing.T) {
+	interval := 40 * time.Millisecond
+	strip := &slowStrip{virtualstrip.New(1, false), interval * 5 / 2}
+	s, err := NewScheduler(strip, 1, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	err = s.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := s.Stats()
+	//Two and a half frames late takes up three slots, so two are dropped
+	if stats.Frames != 1 || stats.Late != 1 || stats.Dropped != 2 {
+		t.Errorf("stats = %+v, want 1 frame, 1 late and 2 dropped", stats)
+	}
+	if stats.LastLatency < interval*5/2 || stats.MaxLatency != stats.LastLatency || stats.AverageLatency != stats.LastLatency {
+		t.Errorf("latencies of a single %v render: %+v", interval*5/2, stats)
+	}
+
+	//The next frame waits for the slot after the ones the late frame took up
+	strip.delay = 0
+	err = s.Fill([]int{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := strip.LastFrame()
+	if at := second.Time.Sub(start); at < 3*interval {
+		t.Errorf("frame after a late one was rendered %v after the first started, want it to wait for its slot at %v", at, 3*interval)
+	}
+	if stats := s.Stats(); stats.Late != 1 || stats.Dropped != 2 {
+		t.Errorf("a frame on time was counted as late: %+v", stats)
+	}
+}
+
+func TestSchedulerPausesArentLate(t *testing.T) {
+	strip := virtualstrip.New(1, false)
+	s, err := NewScheduler(strip, 1, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		err = s.Fill([]int{i + 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		//A hold between frames, such as a forecast shown for a while, restarts the schedule
+		time.Sleep(50 * time.Millisecond)
+	}
+	if stats := s.Stats(); stats.Late != 0 || stats.Dropped != 0 {
+		t.Errorf("pauses between renders were counted as late: %+v", stats)
+	}
+}
@@ -0,0 +1,126 @@
+package ledstrip
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+//MaxBrightness is the brightness of a strip that isn't dimmed at all.
+const MaxBrightness = 255
+
+//rampInterval is how often a brightness ramp re-renders the strip. 50 steps a second looks smooth.
+const rampInterval = 20 * time.Millisecond
+
+//brightness is the software brightness of a strip, which may be ramping from one level to another.
+type brightness struct {
+	level    int //the level the strip is at, or is ramping towards
+	from     int //the level the current ramp started at
+	start    time.Time
+	duration time.Duration
+	stop     chan struct{} //closed to stop the goroutine of the current ramp
+}
+
+//current returns the brightness at a point in time.
+func (b *brightness) current(now time.Time) int {
+	elapsed := now.Sub(b.start)
+	if b.duration <= 0 || elapsed >= b.duration {
+		return b.level
+	}
+	progress := float64(elapsed) / float64(b.duration)
+	return b.from + int(math.Round(float64(b.level-b.from)*progress))
+}
+
+//stopRamp stops the current ramp, if there is one.
+func (b *brightness) stopRamp() {
+	if b.stop != nil {
+		close(b.stop)
+		b.stop = nil
+	}
+}
+
+//Brightness returns the current software brightness of the strip, between 0 and 255.
+func (l *LedStrip) Brightness() int {
+	l.front.Lock()
+	defer l.front.Unlock()
+	return l.brightness.current(time.Now())
+}
+
+//SetBrightness changes the software brightness of the strip immediately. Level is between 0 and 255.
+//Every color is scaled by level/255 on its way to the LEDs, on top of the hardware brightness the strip was initialized with.
+//The last rendered frame is re-rendered at the new level right away, pending changes are left pending.
+//Any ramp in progress is stopped.
+func (l *LedStrip) SetBrightness(level int) error {
+	err := validateBrightness(level)
+	if err != nil {
+		return err
+	}
+	l.front.Lock()
+	defer l.front.Unlock()
+	l.brightness.stopRamp()
+	l.brightness = brightness{level: level}
+	return l.push()
+}
+
+//RampBrightness smoothly changes the software brightness of the strip to level over the supplied duration.
+//The strip re-renders its last frame on its own while the ramp runs, so the ramp stays smooth even when
+//nothing else is rendering. Starting a new ramp, or calling SetBrightness, stops the current one.
+//Example:
+//	strip.RampBrightness(30, 10*time.Minute) //dim the map for the night
+func (l *LedStrip) RampBrightness(level int, duration time.Duration) error {
+	err := validateBrightness(level)
+	if err != nil {
+		return err
+	}
+	if duration <= 0 {
+		return l.SetBrightness(level)
+	}
+	l.front.Lock()
+	defer l.front.Unlock()
+	now := time.Now()
+	from := l.brightness.current(now)
+	l.brightness.stopRamp()
+	stop := make(chan struct{})
+	l.brightness = brightness{
+		level:    level,
+		from:     from,
+		start:    now,
+		duration: duration,
+		stop:     stop,
+	}
+	go l.ramp(stop, now.Add(duration))
+	return nil
+}
+
+//ramp re-renders the last frame until the ramp ends or is stopped.
+//Render errors are dropped, the next frame rendered by the strip's user will report them.
+func (l *LedStrip) ramp(stop chan struct{}, end time.Time) {
+	ticker := time.NewTicker(rampInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			l.front.Lock()
+			select {
+			case <-stop:
+				l.front.Unlock()
+				return
+			default:
+			}
+			l.push()
+			l.front.Unlock()
+			if !now.Before(end) {
+				return
+			}
+		}
+	}
+}
+
+func validateBrightness(level int) error {
+	if level < 0 || level > MaxBrightness {
+		return fmt.Errorf("brightness must be between 0 and %v, got: %v", MaxBrightness, level)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package ledstrip
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"led-map/ledcolor"
+	"math"
+)
+
+//DefaultGamma returns the gamma a backend applies to colors that have no gamma in the calibration.
+//The ws281x backend uses 2.8, the curve its driver used to apply itself, so WS2812s look the same as they always have.
+//Every other backend is linear, so previews and network receivers get colors exactly as they were set.
+func DefaultGamma(backend Backend) float64 {
+	if backend == Hardware {
+		return hardwareGamma
+	}
+	return 1
+}
+
+//hardwareGamma is the exponent of the ws2811 driver's default gamma8 table.
+const hardwareGamma = 2.8
+
+//ChannelFactors holds one value for each of the red, green and blue LEDs.
+type ChannelFactors struct {
+	R float64 `json:"r"`
+	G float64 `json:"g"`
+	B float64 `json:"b"`
+}
+
+//or returns the red, green and blue factors, with def in place of any that were left at 0.
+func (f ChannelFactors) or(def float64) [3]float64 {
+	factors := [3]float64{f.R, f.G, f.B}
+	for i, factor := range factors {
+		if factor == 0 {
+			factors[i] = def
+		}
+	}
+	return factors
+}
+
+//Calibration describes how a particular strip has to be corrected so colors look the way they were meant to.
+//Two maps built from different batches of LEDs should show the same color for the same temperature once both are calibrated.
+//Every field is optional, and so is every color in a field. The zero value only applies the backend's default gamma.
+//
+//Gamma is only ever applied here, in software. The ws2811 driver is given a linear gamma table, so colors are never corrected twice.
+type Calibration struct {
+	//Gamma is the exponent of each color's gamma curve. WS2812s usually look right somewhere between 2.2 and 2.8.
+	//Colors left at 0 use the backend's default gamma, see DefaultGamma.
+	Gamma ChannelFactors `json:"gamma"`
+	//WhitePoint scales each color, between 0 and 1, so that full white looks neutral instead of blue or green.
+	//Colors left at 0 aren't scaled.
+	WhitePoint ChannelFactors `json:"whitePoint"`
+	//ColorTemperature shifts white towards the given temperature in Kelvin. Around 6600K is the strip's own white.
+	ColorTemperature float64 `json:"colorTemperature"`
+	//Trim scales each color of single LEDs, between 0 and 1, to even out LEDs that are brighter than their neighbors.
+	//Colors left at 0 aren't scaled.
+	Trim map[int]ChannelFactors `json:"trim"`
+}
+
+//LoadCalibration reads a calibration from a JSON file.
+//Example file:
+//	{"gamma": {"r": 2.6, "g": 2.8, "b": 2.5}, "whitePoint": {"r": 1, "g": 0.85, "b": 0.7}, "colorTemperature": 5000, "trim": {"17": {"r": 0.9, "g": 1, "b": 1}}}
+func LoadCalibration(path string) (Calibration, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Calibration{}, err
+	}
+	c := Calibration{}
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return Calibration{}, fmt.Errorf("could not read calibration from %v: %v", path, err)
+	}
+	return c, c.Validate()
+}
+
+//Validate checks that every value in the calibration is in range.
+func (c Calibration) Validate() error {
+	if c.Gamma.R < 0 || c.Gamma.G < 0 || c.Gamma.B < 0 {
+		return fmt.Errorf("gamma can't be negative, got: %+v", c.Gamma)
+	}
+	err := validateFactors("white point", c.WhitePoint)
+	if err != nil {
+		return err
+	}
+	if c.ColorTemperature != 0 && (c.ColorTemperature < 1000 || c.ColorTemperature > 40000) {
+		return fmt.Errorf("color temperature must be between 1000K and 40000K, got: %v", c.ColorTemperature)
+	}
+	for index, trim := range c.Trim {
+		if index < 0 {
+			return fmt.Errorf("trim index can't be negative, got: %v", index)
+		}
+		err := validateFactors(fmt.Sprintf("trim of LED %v", index), trim)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateFactors(name string, f ChannelFactors) error {
+	if f.R < 0 || f.R > 1 || f.G < 0 || f.G > 1 || f.B < 0 || f.B > 1 {
+		return fmt.Errorf("%v must be between 0 and 1, got: %+v", name, f)
+	}
+	return nil
+}
+
+//SetCalibration changes the calibration applied to every frame, and re-renders the last frame with it.
+func (l *LedStrip) SetCalibration(c Calibration) error {
+	err := c.Validate()
+	if err != nil {
+		return err
+	}
+	l.front.Lock()
+	defer l.front.Unlock()
+	l.calibration = compileCalibration(c, l.gamma)
+	return l.push()
+}
+
+//calibration is a Calibration turned into lookup tables, so applying it to a frame is cheap.
+type calibration struct {
+	gamma [3][256]uint8      //red, green and blue gamma curves
+	white [3]float64         //white point and color temperature, combined
+	trim  map[int][3]float64 //per-LED trim
+}
+
+//compileCalibration builds the lookup tables for a calibration. Colors without a gamma use defaultGamma.
+func compileCalibration(c Calibration, defaultGamma float64) *calibration {
+	compiled := &calibration{
+		white: c.WhitePoint.or(1),
+		trim:  make(map[int][3]float64, len(c.Trim)),
+	}
+	for channel, gamma := range c.Gamma.or(defaultGamma) {
+		for i := 0; i < 256; i++ {
+			compiled.gamma[channel][i] = uint8(math.Round(255 * math.Pow(float64(i)/255, gamma)))
+		}
+	}
+	if c.ColorTemperature != 0 {
+		r, g, b := kelvinToRgb(c.ColorTemperature)
+		compiled.white[0] *= r
+		compiled.white[1] *= g
+		compiled.white[2] *= b
+	}
+	for index, trim := range c.Trim {
+		compiled.trim[index] = trim.or(1)
+	}
+	return compiled
+}
+
+//apply corrects one LED's red, green and blue values, dimming them to the supplied brightness on the way.
+//Brightness is applied before the gamma curve, so dimming looks even to the eye.
+func (c *calibration) apply(index int, color ledcolor.Color, level int) ledcolor.Color {
+	trim, trimmed := c.trim[index]
+	rgb := [3]*uint8{&color.R, &color.G, &color.B}
+	for channel, value := range rgb {
+		factor := c.white[channel] * float64(level) / MaxBrightness
+		if trimmed {
+			factor *= trim[channel]
+		}
+		corrected := int(math.Round(float64(*value) * factor))
+		if corrected > 255 {
+			corrected = 255
+		}
+		*value = c.gamma[channel][corrected]
+	}
+	return color
+}
+
+//kelvinToRgb approximates the color of a black body at the given temperature, as factors between 0 and 1.
+//This is Tanner Helland's fit of the CIE 1964 color matching data, which is plenty accurate for LEDs.
+func kelvinToRgb(kelvin float64) (r, g, b float64) {
+	t := kelvin / 100
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+	if t >= 66 {
+		b = 255
+	} else if t <= 19 {
+		b = 0
+	} else {
+		b = 138.5177312231*math.Log(t-10) - 305.0447876799
+	}
+	return clampFactor(r / 255), clampFactor(g / 255), clampFactor(b / 255)
+}
+
+func clampFactor(f float64) float64 {
+	return math.Max(0, math.Min(1, f))
+}
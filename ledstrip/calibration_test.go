@@ -0,0 +1,88 @@
+package ledstrip
+
+import (
+	"led-map/ledcolor"
+	"testing"
+)
+
+func TestCalibrationGammaTables(t *testing.T) {
+	linear := compileCalibration(Calibration{}, 1)
+	hardware := compileCalibration(Calibration{}, hardwareGamma)
+	mixed := compileCalibration(Calibration{Gamma: ChannelFactors{G: 2.2}}, 1)
+	for i := 0; i < 256; i++ {
+		for channel := 0; channel < 3; channel++ {
+			if got := linear.gamma[channel][i]; got != uint8(i) {
+				t.Fatalf("linear gamma of %v on channel %v = %v", i, channel, got)
+			}
+		}
+		if i > 0 && hardware.gamma[0][i] < hardware.gamma[0][i-1] {
+			t.Fatalf("gamma 2.8 table isn't increasing at %v", i)
+		}
+	}
+	if got := hardware.gamma[0][128]; got != 37 {
+		t.Errorf("gamma 2.8 of 128 = %v, want 37", got)
+	}
+	if got := hardware.gamma[2][255]; got != 255 {
+		t.Errorf("gamma 2.8 of 255 = %v, want 255", got)
+	}
+	//Only green was given a gamma, red and blue keep the default
+	if mixed.gamma[0][128] != 128 || mixed.gamma[2][128] != 128 {
+		t.Errorf("red and blue without a gamma should be linear, got %v and %v", mixed.gamma[0][128], mixed.gamma[2][128])
+	}
+	if got := mixed.gamma[1][128]; got != 56 {
+		t.Errorf("gamma 2.2 of 128 = %v, want 56", got)
+	}
+}
+
+func TestCalibrationPartialFactors(t *testing.T) {
+	tests := []struct {
+		name  string
+		c     Calibration
+		index int
+		want  ledcolor.Color
+	}{
+		{"nothing", Calibration{}, 0, ledcolor.Color{R: 200, G: 200, B: 200}},
+		{"white point", Calibration{WhitePoint: ChannelFactors{R: 1, G: 0.5, B: 0.25}}, 0, ledcolor.Color{R: 200, G: 100, B: 50}},
+		{"white point red only", Calibration{WhitePoint: ChannelFactors{R: 0.5}}, 0, ledcolor.Color{R: 100, G: 200, B: 200}},
+		{"trim red only", Calibration{Trim: map[int]ChannelFactors{17: {R: 0.5}}}, 17, ledcolor.Color{R: 100, G: 200, B: 200}},
+		{"trim on another LED", Calibration{Trim: map[int]ChannelFactors{17: {R: 0.5}}}, 16, ledcolor.Color{R: 200, G: 200, B: 200}},
+		{"white point and trim", Calibration{WhitePoint: ChannelFactors{B: 0.5}, Trim: map[int]ChannelFactors{3: {B: 0.5}}}, 3, ledcolor.Color{R: 200, G: 200, B: 50}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.c.Validate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			compiled := compileCalibration(test.c, 1)
+			got := compiled.apply(test.index, ledcolor.Color{R: 200, G: 200, B: 200}, MaxBrightness)
+			if got != test.want {
+				t.Errorf("calibrated color = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCalibrationBrightness(t *testing.T) {
+	compiled := compileCalibration(Calibration{}, 1)
+	got := compiled.apply(0, ledcolor.Color{R: 200, G: 100, B: 0}, MaxBrightness/2)
+	want := ledcolor.Color{R: 100, G: 50, B: 0}
+	if got.R < want.R-1 || got.R > want.R+1 || got.G < want.G-1 || got.G > want.G+1 || got.B != 0 {
+		t.Errorf("color at half brightness = %+v, want about %+v", got, want)
+	}
+}
+
+func TestCalibrationValidate(t *testing.T) {
+	bad := []Calibration{
+		{Gamma: ChannelFactors{R: -1}},
+		{WhitePoint: ChannelFactors{G: 1.5}},
+		{ColorTemperature: 500},
+		{Trim: map[int]ChannelFactors{-1: {R: 1}}},
+		{Trim: map[int]ChannelFactors{3: {B: -0.5}}},
+	}
+	for _, c := range bad {
+		if c.Validate() == nil {
+			t.Errorf("calibration %+v was accepted", c)
+		}
+	}
+}
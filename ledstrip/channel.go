@@ -0,0 +1,76 @@
+package ledstrip
+
+import "fmt"
+
+//ChannelStrip is a single channel of a LedStrip, addressed on its own.
+//It shares its LEDs with the LedStrip it came from, and rendering it renders every channel.
+type ChannelStrip struct {
+	strip   *LedStrip
+	channel int
+}
+
+//Channel returns one channel of the strip, so each channel can be handed to its own ColorFiller user.
+func (l *LedStrip) Channel(channel int) (*ChannelStrip, error) {
+	if channel < 0 || channel >= l.channels {
+		return &ChannelStrip{}, fmt.Errorf("channel %v does not exist, the strip has %v channels", channel, l.channels)
+	}
+	return &ChannelStrip{l, channel}, nil
+}
+
+//FillSingle applies a color to all LEDs on the channel.
+func (c *ChannelStrip) FillSingle(color int) error {
+	c.strip.mu.Lock()
+	colors := c.colors()
+	for i := 0; i < len(colors); i++ {
+		colors[i] = color
+	}
+	return c.strip.written()
+}
+
+//Fill applies an array of colors to all LEDs on the channel. The array of colors must be the same length as the channel.
+func (c *ChannelStrip) Fill(colors []int) error {
+	c.strip.mu.Lock()
+	leds := c.colors()
+	if len(colors) != len(leds) {
+		c.strip.mu.Unlock()
+		return fmt.Errorf("mismatch between number of colors and number of LEDs. colors = %v, LEDs = %v", len(colors), len(leds))
+	}
+	copy(leds, colors)
+	return c.strip.written()
+}
+
+//Set sets a single LED's color.
+func (c *ChannelStrip) Set(index int, color int) error {
+	c.strip.mu.Lock()
+	leds := c.colors()
+	if index >= len(leds) || index < 0 {
+		c.strip.mu.Unlock()
+		return fmt.Errorf("index is out of bounds")
+	}
+	leds[index] = color
+	return c.strip.written()
+}
+
+//Render pushes all pending color changes on every channel to the LEDs.
+func (c *ChannelStrip) Render() error {
+	return c.strip.Render()
+}
+
+//Strip returns the LedStrip the channel belongs to.
+func (c *ChannelStrip) Strip() *LedStrip {
+	return c.strip
+}
+
+//Len returns the number of LEDs on the channel.
+func (c *ChannelStrip) Len() int {
+	return len(c.strip.leds.Leds(c.channel))
+}
+
+//colors returns the part of the strip's colors that belongs to this channel.
+func (c *ChannelStrip) colors() []int {
+	offset := 0
+	for channel := 0; channel < c.channel; channel++ {
+		offset += len(c.strip.leds.Leds(channel))
+	}
+	return c.strip.colors[offset : offset+c.Len()]
+}
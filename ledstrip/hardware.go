@@ -0,0 +1,62 @@
+//go:build ws281x
+// +build ws281x
+
+package ledstrip
+
+import (
+	"led-map/ledcolor"
+
+	ws2811 "github.com/rpi-ws281x/rpi-ws281x-go"
+)
+
+//HardwareAvailable reports whether this binary was built with the ws281x tag, and can therefore drive a real strip.
+const HardwareAvailable = true
+
+//newHardwareDriver initializes the rpi-ws281x driver with one ws2811 channel for each configured channel.
+//The options must already be validated.
+func newHardwareDriver(opt Options) (driver, error) {
+	wsOpt := ws2811.Option{
+		RenderWaitTime: opt.RenderWaitTime,
+		Frequency:      opt.Frequency,
+		DmaNum:         opt.DmaNum,
+		Channels:       make([]ws2811.ChannelOption, len(opt.Channels)),
+	}
+	for i, channel := range opt.Channels {
+		wsOpt.Channels[i] = ws2811.ChannelOption{
+			GpioPin:    channel.GpioPin,
+			Invert:     channel.Invert,
+			LedCount:   channel.LedCount,
+			StripeType: stripeType(channel.order(Hardware)),
+			Brightness: opt.Brightness,
+			Gamma:      linearGamma(),
+		}
+	}
+	dev, err := ws2811.MakeWS2811(&wsOpt)
+	if err != nil {
+		return nil, err
+	}
+	err = dev.Init()
+	if err != nil {
+		return nil, err
+	}
+	return dev, nil
+}
+
+//linearGamma returns a gamma table that leaves every value as it is.
+//Gamma is owned by the strip's calibration, so the driver must not apply its own curve on top of it.
+func linearGamma() []byte {
+	table := make([]byte, 256)
+	for i := range table {
+		table[i] = byte(i)
+	}
+	return table
+}
+
+//stripeType returns the ws2811 strip type that sends a packed color exactly as ledcolor.Order.Pack laid it out.
+//The LedStrip has already put the channels in the strip's order, so the driver must not reorder them again.
+func stripeType(order ledcolor.Order) int {
+	if order.HasWhite() {
+		return ws2811.SK6812StripRGBW
+	}
+	return ws2811.WS2811StripRGB
+}
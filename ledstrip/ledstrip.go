@@ -2,97 +2,291 @@
 //It provides convenient methods for setting the colors of all
 //LEDs in the map at the same time.
 //It assumes you're using a string of LEDs, not a matrix, so it is one-dimensional.
+//
+//The strip can be backed by real hardware or kept entirely in memory. Clocked strips such as the APA102
+//are driven through the Pi's SPI device in pure Go. The ws281x backend wraps the rpi-ws281x C library
+//and is only compiled in with the ws281x build tag:
+//	go build -tags ws281x
+//Without that tag the module is pure Go, and builds and runs on any machine.
 package ledstrip
 
 import (
 	"fmt"
+	"led-map/ledcolor"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Backend names the device a LedStrip pushes its colors to.
+type Backend string
 
-	ws2811 "github.com/rpi-ws281x/rpi-ws281x-go"
+const (
+	//Hardware drives a real strip through rpi-ws281x. It is only available when HardwareAvailable is true.
+	Hardware Backend = "ws281x"
+	//Memory keeps the colors in memory and never touches any hardware.
+	Memory Backend = "memory"
+	//SPI drives an APA102 or SK9822 strip by writing its frames to an SPI device such as /dev/spidev0.0.
+	SPI Backend = "spi"
 )
 
-//LedStrip represents a string of LEDs
+//BackendEnv is the environment variable Init reads to choose a backend at runtime.
+const BackendEnv = "LED_BACKEND"
+
+//driver is the low-level device behind a LedStrip. *ws2811.WS2811 satisfies it.
+type driver interface {
+	Leds(channel int) []uint32
+	Render() error
+	Fini()
+}
+
+//LedStrip represents a string of LEDs.
+//When more than one channel is in use, the channels are joined end to end into one logical strip:
+//index 0 is the first LED of channel 0, and the first LED of channel 1 comes right after the last LED of channel 0.
+//
+//Colors are kept exactly as they were set, and only processed on their way to the driver when the strip is rendered.
+//That way software brightness can be changed at any time without losing any color information.
+//
+//The strip is double-buffered and safe for concurrent use. Writers fill a back buffer under one lock, and Render
+//copies it to the front buffer in one step, under a second lock that covers the driver. The back buffer's lock is
+//never held while waiting for the driver, so writers can fill the next frame while the driver is still rendering
+//the last one, and a render never shows a half-written Fill. With autoFill, each write renders, so it does wait.
 type LedStrip struct {
-	leds     *ws2811.WS2811
-	autoFill bool
+	mu          sync.Mutex //guards the back buffer, colors. Never held while waiting for front
+	front       sync.Mutex //guards the front buffer, rendered, and everything that turns it into driver colors. Taken before mu
+	leds        driver
+	channels    int
+	orders      []ledcolor.Order //color order of each channel
+	autoFill    bool
+	colors      []int //colors that have been set, rendered or not
+	rendered    []int //colors as they were at the last render, before any processing
+	brightness  brightness
+	calibration *calibration
+	gamma       float64 //gamma used for colors the calibration has no gamma for
+	power       PowerBudget
+	powerStats  PowerStats
+	hardware    int //brightness the driver dims every frame by, on top of everything done in software
 }
 
 //Init returns an initialized led map with the supplied number of LEDs and brightness.
-//Brightness is an integer between 0 and 255, and cannot be changed after initialization.
-//If you want fine control over brightness, initalize the map with a brightness of 255
-//and control the brightness through the color variables you pass.
+//Brightness is an integer between 0 and 255. It is the hardware brightness, and cannot be changed after initialization.
+//If you want to change brightness later, initalize the map with a brightness of 255
+//and use SetBrightness or RampBrightness, which scale every frame in software.
 //AutoFill gives you control over the rendering of your lights. If you want to have control over
 //when color changes you apply actually get pushed to the LED strip, set this to false.
+//The backend is chosen by DefaultBackend.
 //Example:
 //	myMap := ledmap.Init(100, 255, false) //100 LEDs with full brightness, has to be manually rendered
 func Init(ledCount int, brightness int, autoFill bool) (*LedStrip, error) {
-	opt := ws2811.DefaultOptions
-	opt.Channels[0].Brightness = brightness
+	opt := DefaultOptions()
+	opt.Brightness = brightness
 	opt.Channels[0].LedCount = ledCount
-	dev, err := ws2811.MakeWS2811(&opt)
-	if err != nil {
-		return &LedStrip{}, err
+	opt.AutoFill = autoFill
+	return InitWithOptions(opt)
+}
+
+//InitBackend works like Init, but uses the supplied backend instead of the default one.
+func InitBackend(backend Backend, ledCount int, brightness int, autoFill bool) (*LedStrip, error) {
+	opt := DefaultOptions()
+	opt.Backend = backend
+	opt.Brightness = brightness
+	opt.Channels[0].LedCount = ledCount
+	opt.AutoFill = autoFill
+	return InitWithOptions(opt)
+}
+
+//InitChannels returns an initialized led map that drives every supplied channel as one logical strip.
+//Brightness and autoFill work the same way they do for Init, and the backend is chosen by DefaultBackend.
+//Example:
+//	myMap, err := ledstrip.InitChannels([]ledstrip.Channel{{GpioPin: 18, LedCount: 60}, {GpioPin: 13, LedCount: 40}}, 255, false)
+func InitChannels(channels []Channel, brightness int, autoFill bool) (*LedStrip, error) {
+	opt := DefaultOptions()
+	opt.Brightness = brightness
+	opt.Channels = channels
+	opt.AutoFill = autoFill
+	return InitWithOptions(opt)
+}
+
+//InitWithOptions returns an initialized led map configured with every hardware parameter in opt.
+//The options are validated first, so a bad combination is reported before the driver is touched.
+//If opt.Backend is empty, the backend is chosen by DefaultBackend.
+//Example:
+//	opt := ledstrip.DefaultOptions()
+//	opt.Channels[0] = ledstrip.Channel{GpioPin: 12, LedCount: 50, Order: ledcolor.BRG}
+//	myMap, err := ledstrip.InitWithOptions(opt)
+func InitWithOptions(opt Options) (*LedStrip, error) {
+	if opt.Backend == "" {
+		backend, err := DefaultBackend()
+		if err != nil {
+			return &LedStrip{}, err
+		}
+		opt.Backend = backend
 	}
-	err = dev.Init()
+	err := opt.Validate()
 	if err != nil {
 		return &LedStrip{}, err
 	}
+	backend := opt.Backend
+
+	var dev driver
+	switch backend {
+	case Hardware:
+		hardware, err := newHardwareDriver(opt)
+		if err != nil {
+			return &LedStrip{}, err
+		}
+		dev = hardware
+	case Memory:
+		dev = newMemoryDriver(opt.Channels)
+	case SPI:
+		spi, err := newSPIDriver(opt)
+		if err != nil {
+			return &LedStrip{}, err
+		}
+		dev = spi
+	default:
+		return &LedStrip{}, fmt.Errorf("unknown LED backend %q", backend)
+	}
+
+	l := &LedStrip{
+		leds:        dev,
+		channels:    len(opt.Channels),
+		orders:      make([]ledcolor.Order, len(opt.Channels)),
+		autoFill:    opt.AutoFill,
+		brightness:  brightness{level: MaxBrightness},
+		calibration: compileCalibration(Calibration{}, DefaultGamma(backend)),
+		gamma:       DefaultGamma(backend),
+		power:       opt.Power,
+		hardware:    opt.Brightness,
+	}
+	for i, channel := range opt.Channels {
+		l.orders[i] = channel.order(backend)
+	}
+	l.colors = make([]int, l.Len())
+	l.rendered = make([]int, l.Len())
+	return l, nil
+}
+
+//DefaultBackend returns the backend named by the LED_BACKEND environment variable.
+//If it isn't set, the hardware backend is used when it was compiled in, and the memory backend otherwise.
+//Falling back to memory logs a warning, once, since a map built without the ws281x tag runs with dark LEDs.
+func DefaultBackend() (Backend, error) {
+	name := os.Getenv(BackendEnv)
+	if name == "" {
+		if HardwareAvailable {
+			return Hardware, nil
+		}
+		warnMemoryOnce.Do(func() {
+			log.Printf("%v isn't set and this binary was built without the ws281x tag, so the LEDs are only kept in memory. "+
+				"Build with -tags ws281x to drive a real strip, or set %v=%v to hide this warning", BackendEnv, BackendEnv, Memory)
+		})
+		return Memory, nil
+	}
+	return ParseBackend(name)
+}
 
-	return &LedStrip{dev, autoFill}, nil
+//warnMemoryOnce makes DefaultBackend warn about falling back to memory only once, however often strips are opened.
+var warnMemoryOnce sync.Once
+
+//ParseBackend converts a backend name, such as "ws281x", "spi" or "memory", into a Backend.
+func ParseBackend(name string) (Backend, error) {
+	backend := Backend(strings.ToLower(strings.TrimSpace(name)))
+	switch backend {
+	case Hardware, Memory, SPI:
+		return backend, nil
+	}
+	return "", fmt.Errorf("unknown LED backend %q", name)
 }
 
 //FillSingle applies a color to all LEDs on the map.
 func (l *LedStrip) FillSingle(color int) error {
-	leds := l.leds.Leds(0)
-	for i := 0; i < len(leds); i++ {
-		leds[i] = uint32(color)
-	}
-	if l.autoFill {
-		err := l.leds.Render()
-		if err != nil {
-			return err
-		}
+	l.mu.Lock()
+	for i := 0; i < len(l.colors); i++ {
+		l.colors[i] = color
 	}
-	return nil
+	return l.written()
 }
 
 //Fill applies an array of colors to all LEDs on the map. The array of colors must be the same length as the LED strip.
 func (l *LedStrip) Fill(colors []int) error {
-	leds := l.leds.Leds(0)
-	if len(colors) != len(leds) {
-		return fmt.Errorf("mismatch between number of colors and number of LEDs. colors = %v, LEDs = %v", len(colors), len(leds))
+	l.mu.Lock()
+	if len(colors) != len(l.colors) {
+		l.mu.Unlock()
+		return fmt.Errorf("mismatch between number of colors and number of LEDs. colors = %v, LEDs = %v", len(colors), len(l.colors))
 	}
 
-	for i := 0; i < len(leds); i++ {
-		leds[i] = uint32(colors[i])
-	}
-	if l.autoFill {
-		err := l.leds.Render()
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	copy(l.colors, colors)
+	return l.written()
 }
 
 //Set sets a single LED's color.
 func (l *LedStrip) Set(index int, color int) error {
-	leds := l.leds.Leds(0)
-	if index > len(leds) || index < 0 {
+	l.mu.Lock()
+	if index >= len(l.colors) || index < 0 {
+		l.mu.Unlock()
 		return fmt.Errorf("index is out of bounds")
 	}
-	leds[index] = uint32(color)
+	l.colors[index] = color
+	return l.written()
+}
+
+//written finishes a change to the back buffer, and renders it if autoFill is true.
+//The caller must hold the lock, written releases it before rendering.
+func (l *LedStrip) written() error {
+	l.mu.Unlock()
 	if l.autoFill {
-		err := l.leds.Render()
-		if err != nil {
-			return err
-		}
+		return l.Render()
 	}
 	return nil
 }
 
+//Len returns the total number of LEDs on every channel of the strip.
+func (l *LedStrip) Len() int {
+	total := 0
+	for channel := 0; channel < l.channels; channel++ {
+		total += len(l.leds.Leds(channel))
+	}
+	return total
+}
+
 //Render pushes all pending color changes to the LED strip.
 //If autoFill is true, there's no reason to use this.
+//Render waits for the driver to finish the last frame before taking the next one from the back buffer.
+//The back buffer is only locked for the copy, so writers never wait for the driver, and frames are always
+//pushed in the order they were rendered.
 func (l *LedStrip) Render() error {
+	l.front.Lock()
+	defer l.front.Unlock()
+	l.mu.Lock()
+	copy(l.rendered, l.colors)
+	l.mu.Unlock()
+	return l.push()
+}
+
+//push processes the last rendered colors, writes them to the driver and renders it.
+//Processing applies the calibration and the software brightness, then packs each color in its channel's order.
+//On RGBW strips, the white part of each color is moved to the white LED before packing.
+//Finally, the frame is dimmed if it would draw more current than the power budget allows.
+//It is also used to re-render the same frame, when only the processing has changed. The caller must hold the front lock.
+func (l *LedStrip) push() error {
+	level := l.brightness.current(time.Now())
+	offset := 0
+	for channel := 0; channel < l.channels; channel++ {
+		leds := l.leds.Leds(channel)
+		order := l.orders[channel]
+		for i := 0; i < len(leds); i++ {
+			color := ledcolor.FromGrb(l.rendered[offset+i])
+			color = l.calibration.apply(offset+i, color, level)
+			if order.HasWhite() {
+				color = color.ExtractWhite()
+			}
+			leds[i] = order.Pack(color)
+		}
+		offset += len(leds)
+	}
+	l.limitPower()
 	err := l.leds.Render()
 	if err != nil {
 		return err
@@ -102,5 +296,8 @@ func (l *LedStrip) Render() error {
 
 //Deinit shuts down the LEDs and releases their memory.
 func (l *LedStrip) Deinit() {
+	l.front.Lock()
+	defer l.front.Unlock()
+	l.brightness.stopRamp()
 	l.leds.Fini()
 }
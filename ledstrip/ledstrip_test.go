@@ -0,0 +1,187 @@
+package ledstrip
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+//checkingDriver is a memory driver that checks every frame it renders was filled in one piece.
+type checkingDriver struct {
+	*memoryDriver
+	frames int
+	torn   int //frames whose LEDs don't all have the same color
+}
+
+func (c *checkingDriver) Render() error {
+	c.frames++
+	leds := c.Leds(0)
+	for _, led := range leds {
+		if led != leds[0] {
+			c.torn++
+			break
+		}
+	}
+	return nil
+}
+
+//memoryStrip returns a strip of ledCount LEDs on the memory backend.
+func memoryStrip(t *testing.T, ledCount int) *LedStrip {
+	t.Helper()
+	l, err := InitBackend(Memory, ledCount, 255, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestConcurrentWritesAndRenders(t *testing.T) {
+	l := memoryStrip(t, 50)
+	checker := &checkingDriver{memoryDriver: newMemoryDriver([]Channel{{LedCount: 50}})}
+	l.leds = checker
+	defer l.Deinit()
+
+	var wg sync.WaitGroup
+	for writer := 0; writer < 4; writer++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			colors := make([]int, 50)
+			for i := 0; i < 500; i++ {
+				for j := range colors {
+					colors[j] = writer<<16 | i
+				}
+				if err := l.Fill(colors); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := l.FillSingle(writer<<8 | i); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(writer)
+	}
+	for renderer := 0; renderer < 2; renderer++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				if err := l.Render(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 2000; i++ {
+			if err := l.Set(i%50, i); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			if err := l.SetBrightness(i % 256); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	if checker.frames == 0 {
+		t.Fatal("no frames were rendered")
+	}
+}
+
+func TestFillIsNeverTorn(t *testing.T) {
+	l := memoryStrip(t, 200)
+	checker := &checkingDriver{memoryDriver: newMemoryDriver([]Channel{{LedCount: 200}})}
+	l.leds = checker
+	defer l.Deinit()
+
+	var wg sync.WaitGroup
+	for writer := 0; writer < 4; writer++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			colors := make([]int, 200)
+			for i := 0; i < 300; i++ {
+				for j := range colors {
+					colors[j] = writer<<16 | i
+				}
+				l.Fill(colors)
+			}
+		}(writer)
+	}
+	for i := 0; i < 300; i++ {
+		l.Render()
+	}
+	wg.Wait()
+	if checker.torn != 0 {
+		t.Errorf("%v of %v frames mixed the colors of two fills", checker.torn, checker.frames)
+	}
+}
+
+func TestWritersDontWaitForTheDriver(t *testing.T) {
+	l := memoryStrip(t, 10)
+	defer l.Deinit()
+
+	//Holding the front lock stands in for a driver that is still rendering, and a second render is waiting for it
+	l.front.Lock()
+	rendered := make(chan error)
+	go func() {
+		rendered <- l.Render()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Fill(make([]int, 10))
+		l.Set(3, 0xffffff)
+		l.FillSingle(0x00ff00)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("writers blocked while the driver was rendering")
+	}
+	l.front.Unlock()
+	<-done
+	err := <-rendered
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenderWaitsForTheLatestFrame(t *testing.T) {
+	l := memoryStrip(t, 3)
+	defer l.Deinit()
+
+	l.front.Lock()
+	rendered := make(chan error)
+	go func() {
+		rendered <- l.Render()
+	}()
+	//Written while the render is waiting for the driver, so it is the frame the render shows
+	l.Fill([]int{1, 2, 3})
+	l.front.Unlock()
+	err := <-rendered
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.front.Lock()
+	defer l.front.Unlock()
+	for i, color := range []int{1, 2, 3} {
+		if l.rendered[i] != color {
+			t.Fatalf("rendered %v, expected [1 2 3]", l.rendered)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package ledstrip
+
+//memoryDriver is a pure-Go stand-in for the rpi-ws281x driver. It keeps the LED colors in memory
+//and rendering does nothing, which lets everything above the strip run on any machine.
+type memoryDriver struct {
+	leds [][]uint32
+}
+
+func newMemoryDriver(channels []Channel) *memoryDriver {
+	leds := make([][]uint32, len(channels))
+	for i, channel := range channels {
+		leds[i] = make([]uint32, channel.LedCount)
+	}
+	return &memoryDriver{leds: leds}
+}
+
+//Leds returns the LEDs of a given channel.
+func (m *memoryDriver) Leds(channel int) []uint32 {
+	return m.leds[channel]
+}
+
+//Render does nothing, there is no hardware to push colors to.
+func (m *memoryDriver) Render() error {
+	return nil
+}
+
+//Fini turns every LED off.
+func (m *memoryDriver) Fini() {
+	for _, channel := range m.leds {
+		for i := range channel {
+			channel[i] = 0
+		}
+	}
+}
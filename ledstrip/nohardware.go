@@ -0,0 +1,14 @@
+//go:build !ws281x
+// +build !ws281x
+
+package ledstrip
+
+import "fmt"
+
+//HardwareAvailable reports whether this binary was built with the ws281x tag, and can therefore drive a real strip.
+const HardwareAvailable = false
+
+//newHardwareDriver always fails, because the rpi-ws281x C library was not compiled in.
+func newHardwareDriver(opt Options) (driver, error) {
+	return nil, fmt.Errorf("the %v backend is unavailable, rebuild with -tags ws281x to drive a real strip", Hardware)
+}
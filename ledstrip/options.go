@@ -0,0 +1,210 @@
+package ledstrip
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"led-map/ledcolor"
+)
+
+const (
+	//MaxChannels is the number of PWM channels the ws2811 driver can drive at the same time.
+	MaxChannels = 2
+	//DefaultGpioPin is the BCM pin used by the first channel when none is given. It is physical pin 12.
+	DefaultGpioPin = 18
+	//DefaultSecondGpioPin is the BCM pin usually used by the second channel. It is physical pin 33.
+	DefaultSecondGpioPin = 13
+	//DefaultFrequency is the signal frequency most strips expect. Some older WS2811 strips need 400kHz.
+	DefaultFrequency = 800000
+	//DefaultDmaNum is the DMA channel used when none is given.
+	DefaultDmaNum = 10
+	//DefaultSPIDevice is the SPI device the SPI backend writes to when none is given. Its MOSI and SCLK are GPIO 10 and 11.
+	DefaultSPIDevice = "/dev/spidev0.0"
+	//DefaultSPISpeed is the SPI clock in Hz. APA102s handle much more, but long strips are more reliable this slow.
+	DefaultSPISpeed = 4000000
+	//MaxSPISpeed is the fastest SPI clock the Pi and the strips agree on.
+	MaxSPISpeed = 32000000
+)
+
+//Every pin the ws2811 driver can send a signal on, grouped by the peripheral it uses.
+//Only the PWM pins can be combined with a second channel.
+var (
+	pwm0Pins = []int{12, 18, 40, 52}
+	pwm1Pins = []int{13, 19, 41, 45, 53}
+	pcmPins  = []int{21, 31}
+	spiPins  = []int{10, 38}
+)
+
+//Channel describes one of the strips attached to the Pi.
+type Channel struct {
+	GpioPin  int            `json:"gpioPin"` //BCM number of the pin the strip's data line is connected to
+	LedCount int            `json:"ledCount"`
+	Order    ledcolor.Order `json:"order"`  //order the strip expects its color channels in, see order for the default
+	Invert   bool           `json:"invert"` //set when the signal goes through an inverting level shifter
+}
+
+//order returns the channel's color order. If none was set, it is the order of an APA102 on the SPI backend,
+//and of a WS2812 everywhere else.
+func (c Channel) order(backend Backend) ledcolor.Order {
+	if c.Order != "" {
+		return c.Order
+	}
+	if backend == SPI {
+		return ledcolor.APA102
+	}
+	return ledcolor.WS2812
+}
+
+//Options holds every hardware parameter of a strip.
+//Start from DefaultOptions and change what you need, since the zero value is not usable.
+type Options struct {
+	Backend        Backend     `json:"backend"`        //empty means DefaultBackend
+	Frequency      int         `json:"frequency"`      //signal frequency in Hz
+	DmaNum         int         `json:"dmaNum"`         //DMA channel, must not be in use by anything else
+	RenderWaitTime int         `json:"renderWaitTime"` //µs to wait between renders, 0 lets the driver work it out
+	Brightness     int         `json:"brightness"`     //between 0 and 255, applies to every channel
+	Channels       []Channel   `json:"channels"`
+	AutoFill       bool        `json:"autoFill"`
+	Power          PowerBudget `json:"power"`     //current the strip draws, and how much of it the supply can deliver
+	SPIDevice      string      `json:"spiDevice"` //device the SPI backend writes to
+	SPISpeed       int         `json:"spiSpeed"`  //SPI clock in Hz
+}
+
+//DefaultOptions returns options for a single WS2812B strip on pin 18 at full brightness. The LED count still has to be set.
+//On the SPI backend, the same options drive a single APA102 strip on the first SPI device.
+func DefaultOptions() Options {
+	return Options{
+		Frequency:  DefaultFrequency,
+		DmaNum:     DefaultDmaNum,
+		Brightness: 255,
+		Channels: []Channel{
+			{GpioPin: DefaultGpioPin},
+		},
+		Power:     DefaultPowerBudget(),
+		SPIDevice: DefaultSPIDevice,
+		SPISpeed:  DefaultSPISpeed,
+	}
+}
+
+//LoadOptions reads options from a JSON file. Anything missing from the file keeps its value from DefaultOptions.
+//If the file doesn't name a backend, it is chosen by DefaultBackend before the options are validated,
+//so a file meant for the backend in LED_BACKEND is checked against that backend.
+//Example file:
+//	{"frequency": 800000, "channels": [{"gpioPin": 18, "ledCount": 60, "order": "GRBW"}]}
+func LoadOptions(path string) (Options, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Options{}, err
+	}
+	opt := DefaultOptions()
+	err = json.Unmarshal(data, &opt)
+	if err != nil {
+		return Options{}, fmt.Errorf("could not read LED options from %v: %v", path, err)
+	}
+	if opt.Backend == "" {
+		opt.Backend, err = DefaultBackend()
+		if err != nil {
+			return Options{}, err
+		}
+	}
+	return opt, opt.Validate()
+}
+
+//Validate checks the options for values and combinations the ws2811 driver can't handle.
+//The memory backend ignores most hardware parameters, but they are still checked so a bad
+//configuration is caught before it reaches a Pi. The SPI backend has no use for GPIO pins, so they aren't checked for it.
+func (o Options) Validate() error {
+	if o.Backend != "" {
+		_, err := ParseBackend(string(o.Backend))
+		if err != nil {
+			return err
+		}
+	}
+	if o.Frequency < 400000 || o.Frequency > 800000 {
+		return fmt.Errorf("frequency must be between 400000 and 800000 Hz, got: %v", o.Frequency)
+	}
+	if o.DmaNum < 0 || o.DmaNum > 14 {
+		return fmt.Errorf("DMA channel must be between 0 and 14, got: %v", o.DmaNum)
+	}
+	if o.DmaNum == 5 {
+		return fmt.Errorf("DMA channel 5 is used by the SD card on newer Pis and would corrupt it, use %v instead", DefaultDmaNum)
+	}
+	if o.RenderWaitTime < 0 {
+		return fmt.Errorf("render wait time can't be negative, got: %v", o.RenderWaitTime)
+	}
+	if o.Brightness < 0 || o.Brightness > 255 {
+		return fmt.Errorf("brightness must be between 0 and 255, got: %v", o.Brightness)
+	}
+	if len(o.Channels) == 0 || len(o.Channels) > MaxChannels {
+		return fmt.Errorf("between 1 and %v channels are supported, got: %v", MaxChannels, len(o.Channels))
+	}
+
+	total := 0
+	for i, channel := range o.Channels {
+		if channel.LedCount < 0 {
+			return fmt.Errorf("channel %v has a negative LED count: %v", i, channel.LedCount)
+		}
+		total += channel.LedCount
+		order := channel.order(o.Backend)
+		err := order.Validate()
+		if err != nil {
+			return fmt.Errorf("channel %v: %v", i, err)
+		}
+		if o.Backend == SPI {
+			if order.HasWhite() {
+				return fmt.Errorf("channel %v: APA102 strips have no white LED, got order %v", i, order)
+			}
+			continue
+		}
+		err = validatePin(i, channel.GpioPin, len(o.Channels))
+		if err != nil {
+			return err
+		}
+	}
+	if total == 0 {
+		return fmt.Errorf("no LEDs configured, set the LED count of at least one channel")
+	}
+	if o.Backend == SPI {
+		if len(o.Channels) != 1 {
+			return fmt.Errorf("the SPI backend drives a single channel, got: %v", len(o.Channels))
+		}
+		if o.SPIDevice == "" {
+			return fmt.Errorf("no SPI device given")
+		}
+		if o.SPISpeed <= 0 || o.SPISpeed > MaxSPISpeed {
+			return fmt.Errorf("SPI speed must be between 1 and %v Hz, got: %v", MaxSPISpeed, o.SPISpeed)
+		}
+	}
+	if len(o.Channels) == 2 && o.Channels[0].GpioPin == o.Channels[1].GpioPin {
+		return fmt.Errorf("both channels use GPIO %v", o.Channels[0].GpioPin)
+	}
+	return o.Power.Validate(total)
+}
+
+//validatePin checks that a channel's pin is wired to a peripheral the driver can use for that channel.
+func validatePin(channel int, pin int, channelCount int) error {
+	switch {
+	case channel == 0 && contains(pwm0Pins, pin):
+		return nil
+	case channel == 1 && contains(pwm1Pins, pin):
+		return nil
+	case channel == 0 && (contains(pcmPins, pin) || contains(spiPins, pin)):
+		if channelCount > 1 {
+			return fmt.Errorf("GPIO %v uses PCM or SPI, which can't be combined with a second channel", pin)
+		}
+		return nil
+	case channel == 0:
+		return fmt.Errorf("channel 0 must use a PWM0 pin %v, a PCM pin %v or an SPI pin %v, got: GPIO %v", pwm0Pins, pcmPins, spiPins, pin)
+	default:
+		return fmt.Errorf("channel 1 must use a PWM1 pin %v, got: GPIO %v", pwm1Pins, pin)
+	}
+}
+
+func contains(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
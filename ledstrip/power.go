@@ -0,0 +1,118 @@
+package ledstrip
+
+import (
+	"fmt"
+	"math"
+)
+
+//PowerBudget describes how much current the strip draws, and how much the power supply can deliver.
+//A typical WS2812B draws about 20mA per color at full brightness, and about 1mA when it is dark.
+type PowerBudget struct {
+	MilliampsPerChannel float64 `json:"milliampsPerChannel"` //current of one color of one LED at full brightness
+	IdleMilliamps       float64 `json:"idleMilliamps"`       //current of one LED that is off
+	MaxMilliamps        float64 `json:"maxMilliamps"`        //what the supply can deliver to the strip, 0 means no limit
+}
+
+//DefaultPowerBudget returns the current draw of a WS2812B strip, with no limit set.
+func DefaultPowerBudget() PowerBudget {
+	return PowerBudget{
+		MilliampsPerChannel: 20,
+		IdleMilliamps:       1,
+	}
+}
+
+//Validate checks that the budget can be met, even by a strip that is completely dark.
+func (p PowerBudget) Validate(ledCount int) error {
+	if p.MilliampsPerChannel < 0 || p.IdleMilliamps < 0 || p.MaxMilliamps < 0 {
+		return fmt.Errorf("power budget values can't be negative, got: %+v", p)
+	}
+	idle := p.IdleMilliamps * float64(ledCount)
+	if p.MaxMilliamps > 0 && idle >= p.MaxMilliamps {
+		return fmt.Errorf("%v LEDs draw %vmA while dark, which is already more than the budget of %vmA", ledCount, idle, p.MaxMilliamps)
+	}
+	return nil
+}
+
+//PowerStats reports the estimated current draw of the last frame, and how often frames had to be dimmed.
+type PowerStats struct {
+	EstimatedMilliamps float64 //what the last frame would have drawn
+	DrawMilliamps      float64 //what the last frame draws after limiting
+	Scale              float64 //what the last frame was scaled by, 1 if it wasn't limited
+	Limited            bool    //whether the last frame was limited
+	LimitedFrames      uint64  //number of frames limited so far
+	Frames             uint64  //number of frames rendered so far
+}
+
+//SetPowerBudget changes the power budget, and re-renders the last frame within it.
+func (l *LedStrip) SetPowerBudget(p PowerBudget) error {
+	err := p.Validate(l.Len())
+	if err != nil {
+		return err
+	}
+	l.front.Lock()
+	defer l.front.Unlock()
+	l.power = p
+	return l.push()
+}
+
+//PowerStats returns the power statistics of the frames rendered so far.
+func (l *LedStrip) PowerStats() PowerStats {
+	l.front.Lock()
+	defer l.front.Unlock()
+	return l.powerStats
+}
+
+//limitPower estimates the current the driver's frame will draw, and dims the whole frame evenly if that is over budget.
+//The estimate is made from the final values sent to the driver, so calibration and brightness are already taken into account.
+//The caller must hold the front lock.
+func (l *LedStrip) limitPower() {
+	var channelTotal float64
+	ledCount := 0
+	for channel := 0; channel < l.channels; channel++ {
+		for _, led := range l.leds.Leds(channel) {
+			for shift := uint(0); shift < 32; shift += 8 {
+				channelTotal += float64((led >> shift) & 0xff)
+			}
+			ledCount++
+		}
+	}
+	idle := l.power.IdleMilliamps * float64(ledCount)
+	milliampsPerStep := l.power.MilliampsPerChannel / 255 * float64(l.hardware) / MaxBrightness
+	active := channelTotal * milliampsPerStep
+
+	stats := PowerStats{
+		EstimatedMilliamps: idle + active,
+		DrawMilliamps:      idle + active,
+		Scale:              1,
+		LimitedFrames:      l.powerStats.LimitedFrames,
+		Frames:             l.powerStats.Frames + 1,
+	}
+	if l.power.MaxMilliamps > 0 && stats.EstimatedMilliamps > l.power.MaxMilliamps {
+		stats.Scale = (l.power.MaxMilliamps - idle) / active
+		stats.Limited = true
+		stats.LimitedFrames++
+		var scaledTotal float64
+		for channel := 0; channel < l.channels; channel++ {
+			leds := l.leds.Leds(channel)
+			for i := range leds {
+				leds[i] = scaleWord(leds[i], stats.Scale)
+				for shift := uint(0); shift < 32; shift += 8 {
+					scaledTotal += float64((leds[i] >> shift) & 0xff)
+				}
+			}
+		}
+		stats.DrawMilliamps = idle + scaledTotal*milliampsPerStep
+	}
+	l.powerStats = stats
+}
+
+//scaleWord dims every byte of a packed color by factor. It doesn't care what order the bytes are in.
+//Values are rounded down, so a scaled frame never draws more than the budget.
+func scaleWord(word uint32, factor float64) uint32 {
+	var scaled uint32
+	for shift := uint(0); shift < 32; shift += 8 {
+		channel := float64((word >> shift) & 0xff)
+		scaled |= uint32(math.Floor(channel*factor)) << shift
+	}
+	return scaled
+}
@@ -0,0 +1,78 @@
+package ledstrip
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+//spiChunkSize is the largest write the spidev kernel driver accepts by default.
+const spiChunkSize = 4096
+
+//spiDriver drives an APA102 or SK9822 strip by writing frames to an SPI device.
+//These strips are clocked, so unlike the ws281x backend there is no timing to get right, and no DMA or PWM involved.
+//Anything that can be written to works as the device, a regular file records the exact byte stream sent to the strip.
+type spiDriver struct {
+	dev        io.WriteCloser
+	leds       []uint32
+	brightness byte //5 bit global brightness sent with every LED
+	frame      []byte
+}
+
+//newSPIDriver opens the SPI device and sets its clock. The options must already be validated.
+//The hardware brightness is sent as every LED's global brightness, so dimming the whole strip costs no color resolution.
+func newSPIDriver(opt Options) (*spiDriver, error) {
+	dev, err := os.OpenFile(opt.SPIDevice, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	err = setSPISpeed(dev, opt.SPISpeed)
+	if err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("could not set the clock of %v to %vHz: %v", opt.SPIDevice, opt.SPISpeed, err)
+	}
+	return &spiDriver{
+		dev:        dev,
+		leds:       make([]uint32, opt.Channels[0].LedCount),
+		brightness: byte((opt.Brightness*31 + 127) / 255),
+	}, nil
+}
+
+//Leds returns the LEDs of a given channel. There is only channel 0.
+func (s *spiDriver) Leds(channel int) []uint32 {
+	return s.leds
+}
+
+//Render writes one frame to the device. A frame is a start frame of 32 zero bits, then 32 bits per LED:
+//three 1 bits, the 5 bit global brightness and the three colors in the order they were packed in.
+//It ends with 32 zero bits, which SK9822s need to latch the frame, followed by half a clock per LED so the
+//data reaches the end of the strip.
+func (s *spiDriver) Render() error {
+	s.frame = s.frame[:0]
+	s.frame = append(s.frame, 0, 0, 0, 0)
+	for _, led := range s.leds {
+		s.frame = append(s.frame, 0xe0|s.brightness, byte(led>>16), byte(led>>8), byte(led))
+	}
+	s.frame = append(s.frame, 0, 0, 0, 0)
+	s.frame = append(s.frame, make([]byte, (len(s.leds)+15)/16)...)
+	for start := 0; start < len(s.frame); start += spiChunkSize {
+		end := start + spiChunkSize
+		if end > len(s.frame) {
+			end = len(s.frame)
+		}
+		_, err := s.dev.Write(s.frame[start:end])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Fini turns every LED off and closes the device.
+func (s *spiDriver) Fini() {
+	for i := range s.leds {
+		s.leds[i] = 0
+	}
+	s.Render()
+	s.dev.Close()
+}
@@ -0,0 +1,20 @@
+package ledstrip
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+//spiIocWrMaxSpeedHz is the spidev ioctl that sets the clock, _IOW('k', 4, __u32).
+const spiIocWrMaxSpeedHz = 0x40046b04
+
+//setSPISpeed sets the clock of an spidev device. Files that aren't SPI devices are left alone.
+func setSPISpeed(dev *os.File, hz int) error {
+	speed := uint32(hz)
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dev.Fd(), spiIocWrMaxSpeedHz, uintptr(unsafe.Pointer(&speed)))
+	if errno != 0 && errno != syscall.ENOTTY {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package ledstrip
+
+import "os"
+
+//setSPISpeed does nothing, spidev devices only exist on Linux.
+func setSPISpeed(dev *os.File, hz int) error {
+	return nil
+}
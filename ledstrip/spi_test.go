@@ -0,0 +1,128 @@
+package ledstrip
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+//tempDir returns a directory that is removed once the test is over.
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "ledstrip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+//spiStrip opens an SPI strip of ledCount LEDs that writes to a regular file, and returns the file's path.
+func spiStrip(t *testing.T, ledCount int, brightness int) (*LedStrip, string) {
+	t.Helper()
+	path := filepath.Join(tempDir(t), "spidev")
+	err := ioutil.WriteFile(path, nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := DefaultOptions()
+	opt.Backend = SPI
+	opt.SPIDevice = path
+	opt.Brightness = brightness
+	opt.Channels[0].LedCount = ledCount
+	l, err := InitWithOptions(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, path
+}
+
+func TestSPIFrame(t *testing.T) {
+	for _, ledCount := range []int{1, 20, 1100} {
+		l, path := spiStrip(t, ledCount, 128)
+		colors := make([]int, ledCount)
+		for i := range colors {
+			colors[i] = 0x112233 //green 0x11, red 0x22, blue 0x33
+		}
+		err := l.Fill(colors)
+		if err == nil {
+			err = l.Render()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Deinit()
+
+		endLength := 4 + (ledCount+15)/16
+		if len(stream) != 4+ledCount*4+endLength {
+			t.Fatalf("%v LEDs: frame is %v bytes long, expected %v", ledCount, len(stream), 4+ledCount*4+endLength)
+		}
+		if !bytes.Equal(stream[:4], []byte{0, 0, 0, 0}) {
+			t.Errorf("%v LEDs: start frame is % x", ledCount, stream[:4])
+		}
+		//Brightness 128 is 16 out of 31, and APA102s take their colors in BGR order
+		led := []byte{0xe0 | 16, 0x33, 0x11, 0x22}
+		for i := 0; i < ledCount; i++ {
+			got := stream[4+i*4 : 8+i*4]
+			if !bytes.Equal(got, led) {
+				t.Fatalf("%v LEDs: LED %v is % x, expected % x", ledCount, i, got, led)
+			}
+		}
+		if !bytes.Equal(stream[4+ledCount*4:], make([]byte, endLength)) {
+			t.Errorf("%v LEDs: end frame is % x, expected %v zero bytes", ledCount, stream[4+ledCount*4:], endLength)
+		}
+	}
+}
+
+func TestSPIFullBrightness(t *testing.T) {
+	l, path := spiStrip(t, 2, 255)
+	defer l.Deinit()
+	err := l.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stream[4] != 0xff || stream[8] != 0xff {
+		t.Errorf("full brightness should be sent as 0xff, got: %#x and %#x", stream[4], stream[8])
+	}
+}
+
+func TestLoadOptionsUsesBackendFromEnvironment(t *testing.T) {
+	path := filepath.Join(tempDir(t), "options.json")
+	//Pin 4 can't carry a ws281x signal, but the SPI backend has no use for it
+	err := ioutil.WriteFile(path, []byte(`{"channels": [{"gpioPin": 4, "ledCount": 10}]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, set := os.LookupEnv(BackendEnv)
+	defer func() {
+		if set {
+			os.Setenv(BackendEnv, previous)
+		} else {
+			os.Unsetenv(BackendEnv)
+		}
+	}()
+
+	os.Setenv(BackendEnv, string(SPI))
+	opt, err := LoadOptions(path)
+	if err != nil {
+		t.Fatalf("SPI options without a usable pin should load, got: %v", err)
+	}
+	if opt.Backend != SPI {
+		t.Errorf("backend should be %v, got: %v", SPI, opt.Backend)
+	}
+	os.Setenv(BackendEnv, string(Memory))
+	_, err = LoadOptions(path)
+	if err == nil {
+		t.Error("pin 4 should be rejected on the memory backend")
+	}
+}
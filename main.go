@@ -1,16 +1,58 @@
 package main
 
 import (
+	"fmt"
+	"led-map/api/weathermapapi"
 	"led-map/compatibility/templed"
 	"led-map/datastore/owmapi"
+	"led-map/diagnostics"
+	"led-map/fseq"
 	"led-map/ledmap"
 	"led-map/ledstrip"
+	"led-map/opc"
+	"led-map/preview"
+	"led-map/recording"
+	"led-map/remap"
+	"led-map/supervisor"
+	"led-map/termstrip"
+	"led-map/timeline"
+	"log"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 const apiBasePath string = "/api"
 
+//terminalBackend draws the map in the terminal instead of on a strip.
+const terminalBackend = "terminal"
+
+//strip is an LED strip that the map can draw on and that can be shut down.
+type strip interface {
+	ledmap.ColorFiller
+	Deinit()
+}
+
 func main() {
+	if addr := os.Getenv("OPC_LISTEN"); addr != "" {
+		serveOpc(addr, 100)
+		return
+	}
+	if path := os.Getenv("LED_REPLAY"); path != "" {
+		replay(path, 100)
+		return
+	}
+	if path := os.Getenv("FSEQ_PLAY"); path != "" {
+		playSequence(path, 100)
+		return
+	}
+	if tests := os.Getenv("LED_DIAGNOSTICS"); tests != "" {
+		runDiagnostics(tests, 100)
+		return
+	}
 	theSamePlace := make([]string, 100)
 	for i := 0; i < 100; i++ {
 		theSamePlace[i] = "2172797"
@@ -23,14 +65,375 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	colors, _ := templed.GetColors(forecast, 40)
-	leds, err := ledstrip.Init(100, 255, false)
+	fps, err := frameRate()
 	if err != nil {
 		panic(err)
 	}
-	defer leds.Deinit()
-	weathermap := ledmap.New(leds, 10, 3)
-	for {
+	colors, err := templed.GetTimeline(forecast, 40)
+	if err != nil {
+		panic(err)
+	}
+	colors.Pace(10*time.Second, 3*time.Second, time.Second/time.Duration(fps))
+	err = colors.Validate()
+	if err != nil {
+		panic(err)
+	}
+	if path := os.Getenv("FSEQ_EXPORT"); path != "" {
+		err := exportSequence(path, colors)
+		if err != nil {
+			panic(err)
+		}
+	}
+	leds, err := openLeds(100)
+	if err != nil {
+		panic(err)
+	}
+	serveAPI(colors, leds)
+	filler, err := withPreview(leds, 100)
+	if err != nil {
+		panic(err)
+	}
+	filler, err = withRecorder(filler, 100)
+	if err != nil {
+		panic(err)
+	}
+	paced, err := ledmap.NewScheduler(filler, 100, fps)
+	if err != nil {
+		panic(err)
+	}
+	weathermap, err := ledmap.New(ledmap.LEDs(paced))
+	if err != nil {
+		panic(err)
+	}
+	onSignal(weathermap.Stop)
+	for !weathermap.Stopped() {
 		weathermap.StartMap(colors)
 	}
+	os.Exit(shutdown(leds, filler))
+}
+
+//serveOpc shows frames from Open Pixel Control clients on the LEDs instead of drawing the weather map.
+func serveOpc(addr string, ledCount int) {
+	leds, err := openLeds(ledCount)
+	if err != nil {
+		panic(err)
+	}
+	server, err := opc.NewServer(leds, ledCount)
+	if err != nil {
+		panic(err)
+	}
+	onSignal(func() {
+		server.Close()
+	})
+	err = server.ListenAndServe(addr)
+	if err != opc.ErrServerClosed {
+		leds.Deinit()
+		panic(err)
+	}
+	os.Exit(shutdown(leds, leds))
+}
+
+//remappedStrip is a strip whose LEDs are remapped, shut down through the strip underneath.
+type remappedStrip struct {
+	*remap.Remapper
+	physical strip
+}
+
+//Deinit shuts down the physical strip.
+func (r remappedStrip) Deinit() {
+	r.physical.Deinit()
+}
+
+//openLeds opens the strip for a map of ledCount LEDs, supervised so that it is re-initialized if rendering keeps failing.
+func openLeds(ledCount int) (*supervisor.Supervisor, error) {
+	return supervisor.New(func() (supervisor.Strip, error) {
+		return openRemapped(ledCount)
+	}, ledCount, supervisor.OnFailure(func(err error) {
+		log.Printf("LEDs: %v", err)
+	}))
+}
+
+//openRemapped opens the strip for a map of ledCount LEDs.
+//If LED_REMAP names a remapping table, the strip is opened with as many LEDs as the table needs, and remapped.
+func openRemapped(ledCount int) (strip, error) {
+	path := os.Getenv("LED_REMAP")
+	if path == "" {
+		return openStrip(ledCount)
+	}
+	table, err := remap.Load(path, ledCount)
+	if err != nil {
+		return nil, err
+	}
+	physical, err := openStrip(table.PhysicalCount(ledCount))
+	if err != nil {
+		return nil, err
+	}
+	remapper, err := remap.New(physical, ledCount, table)
+	if err != nil {
+		physical.Deinit()
+		return nil, err
+	}
+	return remappedStrip{remapper, physical}, nil
+}
+
+//openStrip opens the strip named by the LED_BACKEND environment variable.
+//If LED_CALIBRATION names a calibration file, it is applied to the strip.
+func openStrip(ledCount int) (strip, error) {
+	if os.Getenv(ledstrip.BackendEnv) == terminalBackend {
+		return termstrip.New(os.Stdout, ledCount, false), nil
+	}
+	leds, err := initLedStrip(ledCount)
+	if err != nil {
+		return nil, err
+	}
+	path := os.Getenv("LED_CALIBRATION")
+	if path == "" {
+		return leds, nil
+	}
+	calibration, err := ledstrip.LoadCalibration(path)
+	if err == nil {
+		err = leds.SetCalibration(calibration)
+	}
+	if err != nil {
+		leds.Deinit()
+		return nil, err
+	}
+	return leds, nil
+}
+
+//initLedStrip initializes the LED strip, configured from the LED_CONFIG options file if there is one.
+func initLedStrip(ledCount int) (*ledstrip.LedStrip, error) {
+	path := os.Getenv("LED_CONFIG")
+	if path == "" {
+		return ledstrip.Init(ledCount, 255, false)
+	}
+	opt, err := ledstrip.LoadOptions(path)
+	if err != nil {
+		return nil, err
+	}
+	leds, err := ledstrip.InitWithOptions(opt)
+	if err != nil {
+		return nil, err
+	}
+	if leds.Len() != ledCount {
+		leds.Deinit()
+		return nil, fmt.Errorf("%v configures %v LEDs, but the map has %v", path, leds.Len(), ledCount)
+	}
+	return leds, nil
+}
+
+//withPreview serves a live browser preview of the map on PREVIEW_ADDR, if it is set.
+//Locations are read from PREVIEW_LOCATIONS, or from the repository's default locations when it isn't set.
+func withPreview(leds ledmap.ColorFiller, ledCount int) (ledmap.ColorFiller, error) {
+	addr := os.Getenv("PREVIEW_ADDR")
+	if addr == "" {
+		return leds, nil
+	}
+	path := os.Getenv("PREVIEW_LOCATIONS")
+	if path == "" {
+		path = weathermapapi.DefaultLocationsFile
+	}
+	locations, err := weathermapapi.LoadLocations(path)
+	if err != nil {
+		return nil, err
+	}
+	p := preview.New(leds, ledCount, locations)
+	go func() {
+		log.Fatal(http.ListenAndServe(addr, p))
+	}()
+	return p, nil
+}
+
+//serveAPI serves the map's timeline and how its LEDs have been doing under apiBasePath on API_ADDR, if it is set.
+func serveAPI(colors timeline.Timeline, leds *supervisor.Supervisor) {
+	addr := os.Getenv("API_ADDR")
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle(apiBasePath+"/timeline", weathermapapi.TimelineHandler(func() timeline.Timeline {
+		return colors
+	}))
+	mux.Handle(apiBasePath+"/leds", weathermapapi.LedStatsHandler(leds.Stats))
+	go func() {
+		log.Fatal(http.ListenAndServe(addr, mux))
+	}()
+}
+
+//withRecorder records every frame of the map to the LED_RECORD file, if it is set.
+func withRecorder(leds ledmap.ColorFiller, ledCount int) (ledmap.ColorFiller, error) {
+	path := os.Getenv("LED_RECORD")
+	if path == "" {
+		return leds, nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return recording.NewRecorder(leds, ledCount, file)
+}
+
+//replay plays the LED_REPLAY recording on the LEDs over and over, instead of drawing the weather map.
+//LED_REPLAY_SPEED scales its speed, 2 plays it twice as fast.
+func replay(path string, ledCount int) {
+	speed := 1.0
+	if s := os.Getenv("LED_REPLAY_SPEED"); s != "" {
+		var err error
+		speed, err = strconv.ParseFloat(s, 64)
+		if err != nil {
+			panic(fmt.Errorf("invalid LED_REPLAY_SPEED %q: %v", s, err))
+		}
+	}
+	leds, err := openLeds(ledCount)
+	if err != nil {
+		panic(err)
+	}
+	var mu sync.Mutex
+	var player *recording.Player
+	stopped := false
+	onSignal(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+		if player != nil {
+			player.Stop()
+		}
+	})
+	for {
+		file, p, err := openRecording(path, ledCount)
+		if err != nil {
+			leds.Deinit()
+			panic(err)
+		}
+		mu.Lock()
+		player = p
+		done := stopped
+		mu.Unlock()
+		if !done {
+			err = p.Play(leds, speed)
+		}
+		file.Close()
+		if err != nil {
+			leds.Deinit()
+			panic(err)
+		}
+		mu.Lock()
+		done = stopped
+		mu.Unlock()
+		if done {
+			os.Exit(shutdown(leds, leds))
+		}
+		if p.Played() == 0 {
+			leds.Deinit()
+			panic(fmt.Errorf("%v has no frames to replay", path))
+		}
+	}
+}
+
+//openRecording opens a recording and checks it was made for the map.
+func openRecording(path string, ledCount int) (*os.File, *recording.Player, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	player, err := recording.NewPlayer(file, ledCount)
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return file, player, nil
+}
+
+//exportSequence writes the map's timeline to an xLights sequence, with the same holds and frame rate the map uses.
+//Frame rates under 4 frames per second are too slow for a version 2 file, so they are written as an uncompressed version 1 file.
+func exportSequence(path string, colors timeline.Timeline) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	seq := fseq.FromTimeline(colors)
+	if seq.StepTime > fseq.MaxV2StepTime {
+		log.Printf("%v steps are too long for a version 2 sequence, writing %v as version 1", seq.StepTime, path)
+		return fseq.Write(file, seq, fseq.Version(1))
+	}
+	return fseq.Write(file, seq, fseq.Compress(fseq.Zlib))
+}
+
+//playSequence plays the FSEQ_PLAY xLights sequence on the LEDs over and over, instead of drawing the weather map.
+func playSequence(path string, ledCount int) {
+	file, err := os.Open(path)
+	if err != nil {
+		panic(err)
+	}
+	seq, err := fseq.Read(file, ledCount)
+	file.Close()
+	if err != nil {
+		panic(err)
+	}
+	leds, err := openLeds(ledCount)
+	if err != nil {
+		panic(err)
+	}
+	sequence, err := ledmap.New(ledmap.LEDs(leds), ledmap.Colors(seq.Timeline()), ledmap.Controller(ledmap.Timed))
+	if err != nil {
+		leds.Deinit()
+		panic(err)
+	}
+	onSignal(sequence.Stop)
+	for {
+		err := sequence.RunMapController()
+		if err == ledmap.ErrStopped {
+			os.Exit(shutdown(leds, leds))
+		}
+		if err != nil {
+			leds.Deinit()
+			panic(err)
+		}
+	}
+}
+
+//defaultFrameRate is the frame rate fades are rendered at, unless LED_FPS sets another one. It matches xLights' default.
+const defaultFrameRate = 20
+
+//frameRate returns the frame rate set by LED_FPS, or defaultFrameRate.
+func frameRate() (int, error) {
+	s := os.Getenv("LED_FPS")
+	if s == "" {
+		return defaultFrameRate, nil
+	}
+	fps, err := strconv.Atoi(s)
+	if err != nil || fps <= 0 || fps > ledmap.MaxFrameRate {
+		return 0, fmt.Errorf("invalid LED_FPS %q, expected a number of frames per second between 1 and %v", s, ledmap.MaxFrameRate)
+	}
+	return fps, nil
+}
+
+//runDiagnostics shows test patterns on the LEDs once, instead of drawing the weather map, and prints what each one should look like.
+//Tests is a comma separated list of diagnostics.Tests, or "all".
+func runDiagnostics(tests string, ledCount int) {
+	var patterns []diagnostics.Pattern
+	if tests == "all" {
+		patterns = diagnostics.All(ledCount)
+	} else {
+		var err error
+		patterns, err = diagnostics.Named(ledCount, strings.Split(tests, ",")...)
+		if err != nil {
+			panic(err)
+		}
+	}
+	leds, err := openLeds(ledCount)
+	if err != nil {
+		panic(err)
+	}
+	stop := make(chan struct{})
+	onSignal(func() {
+		close(stop)
+	})
+	err = diagnostics.Run(leds, patterns, os.Stdout, stop)
+	if err != nil && err != ledmap.ErrStopped {
+		leds.Deinit()
+		panic(err)
+	}
+	os.Exit(shutdown(leds, leds))
 }
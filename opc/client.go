@@ -0,0 +1,144 @@
+package opc
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//dialTimeout is how long connecting to a server may take before a render gives up.
+const dialTimeout = 2 * time.Second
+
+//Client is a ColorFiller that streams frames to an OPC server.
+type Client struct {
+	mu       sync.Mutex
+	addr     string
+	conn     net.Conn //nil until connected, and again after the connection breaks
+	channel  int
+	pending  []int
+	autoFill bool
+}
+
+//NewClient returns a Client that sends the colors of ledCount LEDs to the OPC server at addr.
+//Addr can include a port, otherwise DefaultPort is used.
+//If the connection breaks, the next render connects again, so the server can be restarted while the map runs.
+//AutoFill behaves the same way it does for ledstrip.LedStrip.
+//Example:
+//	fadecandy, err := opc.NewClient("localhost", 100, false, opc.Channel(1))
+func NewClient(addr string, ledCount int, autoFill bool, opts ...option) (*Client, error) {
+	c, err := newConfig(opts)
+	if err != nil {
+		return &Client{}, err
+	}
+	if ledCount*3 > maxMessageLength {
+		return &Client{}, fmt.Errorf("an OPC message can hold at most %v LEDs, got: %v", maxMessageLength/3, ledCount)
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(DefaultPort))
+	}
+	client := &Client{
+		addr:     addr,
+		channel:  c.channel,
+		pending:  make([]int, ledCount),
+		autoFill: autoFill,
+	}
+	err = client.connect()
+	if err != nil {
+		return &Client{}, err
+	}
+	return client, nil
+}
+
+//connect opens the connection to the server if it isn't already open. The caller must hold the lock, or own the client.
+func (c *Client) connect() error {
+	if c.conn != nil {
+		return nil
+	}
+	conn, err := net.DialTimeout("tcp", c.addr, dialTimeout)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	return nil
+}
+
+//FillSingle applies a color to all LEDs.
+func (c *Client) FillSingle(color int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.pending {
+		c.pending[i] = color
+	}
+	if c.autoFill {
+		return c.render()
+	}
+	return nil
+}
+
+//Fill applies an array of colors to all LEDs. The array of colors must be the same length as the number of LEDs.
+func (c *Client) Fill(colors []int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(colors) != len(c.pending) {
+		return fmt.Errorf("mismatch between number of colors and number of LEDs. colors = %v, LEDs = %v", len(colors), len(c.pending))
+	}
+	copy(c.pending, colors)
+	if c.autoFill {
+		return c.render()
+	}
+	return nil
+}
+
+//Set sets a single LED's color.
+func (c *Client) Set(index int, color int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if index >= len(c.pending) || index < 0 {
+		return fmt.Errorf("index is out of bounds")
+	}
+	c.pending[index] = color
+	if c.autoFill {
+		return c.render()
+	}
+	return nil
+}
+
+//Render sends the frame to the server.
+//If autoFill is true, there's no reason to use this.
+func (c *Client) Render() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.render()
+}
+
+//render sends the pending colors, connecting first if the connection was lost. The caller must hold the lock.
+func (c *Client) render() error {
+	err := c.connect()
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Write(encodeFrame(c.channel, c.pending))
+	if err != nil {
+		c.conn.Close()
+		c.conn = nil
+		return err
+	}
+	return nil
+}
+
+//Deinit turns every LED off and closes the connection.
+func (c *Client) Deinit() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return
+	}
+	for i := range c.pending {
+		c.pending[i] = 0
+	}
+	c.conn.Write(encodeFrame(c.channel, c.pending))
+	c.conn.Close()
+	c.conn = nil
+}
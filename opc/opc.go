@@ -0,0 +1,71 @@
+//Package opc speaks Open Pixel Control, the simple TCP protocol used by fadecandy, gl_server and many LED visualizers.
+//A Client is a ColorFiller that streams frames to an OPC server, so a map can be drawn by any of those tools.
+//A Server does the opposite: it accepts OPC frames from other tools and shows them on a local strip,
+//which turns the Pi into a plain pixel sink.
+//
+//Every OPC message is a channel, a command and a big-endian length, followed by that many bytes of data.
+//Channel 0 is a broadcast to every channel. The only command used here is set pixel colors,
+//whose data is red, green and blue for each pixel in turn.
+package opc
+
+import (
+	"fmt"
+	"led-map/ledcolor"
+)
+
+const (
+	//DefaultPort is the TCP port OPC servers usually listen on.
+	DefaultPort = 7890
+	//Broadcast is the channel that addresses every channel of a server.
+	Broadcast = 0
+)
+
+//Commands and header layout
+const (
+	headerLength     = 4
+	setPixelColors   = 0
+	maxMessageLength = 0xffff
+)
+
+//config holds the settings shared by clients and servers.
+type config struct {
+	channel int
+}
+
+//Function used to set options
+type option func(*config)
+
+//Channel provides an option for setting the OPC channel.
+//A client sends its frames on the channel, and a server only shows frames sent on it or broadcast to every channel.
+//It is Broadcast by default.
+func Channel(channel int) option {
+	return func(c *config) {
+		c.channel = channel
+	}
+}
+
+//newConfig applies the options over the defaults.
+func newConfig(opts []option) (config, error) {
+	c := config{channel: Broadcast}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.channel < 0 || c.channel > 255 {
+		return config{}, fmt.Errorf("OPC channels must be between 0 and 255, got: %v", c.channel)
+	}
+	return c, nil
+}
+
+//encodeFrame builds a set pixel colors message from colors packed the way utilities.HsvToGrb packs them.
+func encodeFrame(channel int, colors []int) []byte {
+	length := len(colors) * 3
+	message := make([]byte, headerLength, headerLength+length)
+	message[0] = byte(channel)
+	message[1] = setPixelColors
+	message[2] = byte(length >> 8)
+	message[3] = byte(length)
+	for _, color := range colors {
+		message = append(message, ledcolor.RGB.Bytes(ledcolor.FromGrb(color))...)
+	}
+	return message
+}
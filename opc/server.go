@@ -0,0 +1,182 @@
+package opc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"led-map/ledcolor"
+	"led-map/ledmap"
+	"net"
+	"strconv"
+	"sync"
+)
+
+//ErrServerClosed is returned by Serve and ListenAndServe once Close has been called.
+var ErrServerClosed = errors.New("opc: server closed")
+
+//Server shows the frames OPC clients send it on a ColorFiller, usually a local ledstrip.LedStrip.
+type Server struct {
+	leds    ledmap.ColorFiller
+	channel int
+
+	mu    sync.Mutex
+	frame []int //last frame shown, so short messages only change the pixels they include
+
+	connMu    sync.Mutex
+	closed    bool
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+}
+
+//NewServer returns a Server that shows frames on leds, which must have ledCount LEDs.
+//The LEDs should not autoFill, the server renders once per message.
+//Example:
+//	sink, err := opc.NewServer(strip, 100, opc.Channel(1))
+//	err = sink.ListenAndServe(":7890")
+func NewServer(leds ledmap.ColorFiller, ledCount int, opts ...option) (*Server, error) {
+	c, err := newConfig(opts)
+	if err != nil {
+		return &Server{}, err
+	}
+	return &Server{
+		leds:      leds,
+		channel:   c.channel,
+		frame:     make([]int, ledCount),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}, nil
+}
+
+//ListenAndServe listens on the TCP address addr and serves OPC clients. An addr without a port uses DefaultPort.
+//It only returns if listening fails, or the server is closed.
+func (s *Server) ListenAndServe(addr string) error {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(DefaultPort))
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+//Serve accepts connections on the listener, and handles each client in its own goroutine.
+//Frames from several clients are shown in the order they arrive. Serve returns when accepting fails,
+//or with ErrServerClosed once the server is closed.
+func (s *Server) Serve(listener net.Listener) error {
+	if !s.track(listener, nil) {
+		listener.Close()
+		return ErrServerClosed
+	}
+	defer s.untrack(listener, nil)
+	defer listener.Close()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			return err
+		}
+		if !s.track(nil, conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go s.handle(conn)
+	}
+}
+
+//Close stops accepting clients and disconnects every connected one, so no frame is shown after it returns.
+func (s *Server) Close() error {
+	s.connMu.Lock()
+	s.closed = true
+	for listener := range s.listeners {
+		listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.connMu.Unlock()
+	//Wait for a frame that is being shown right now
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return nil
+}
+
+//track adds a listener or connection to the ones Close shuts down. It reports false if the server is already closed.
+func (s *Server) track(listener net.Listener, conn net.Conn) bool {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	if s.closed {
+		return false
+	}
+	if listener != nil {
+		s.listeners[listener] = struct{}{}
+	}
+	if conn != nil {
+		s.conns[conn] = struct{}{}
+	}
+	return true
+}
+
+//untrack removes a listener or connection that has been shut down.
+func (s *Server) untrack(listener net.Listener, conn net.Conn) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	delete(s.listeners, listener)
+	delete(s.conns, conn)
+}
+
+func (s *Server) isClosed() bool {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	return s.closed
+}
+
+//handle reads messages from one client until it disconnects.
+//If the LEDs fail to show a frame, the connection is closed so the client notices.
+func (s *Server) handle(conn net.Conn) {
+	defer s.untrack(nil, conn)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	header := make([]byte, headerLength)
+	for {
+		_, err := io.ReadFull(reader, header)
+		if err != nil {
+			return
+		}
+		data := make([]byte, int(header[2])<<8|int(header[3]))
+		_, err = io.ReadFull(reader, data)
+		if err != nil {
+			return
+		}
+		channel, command := int(header[0]), header[1]
+		if command != setPixelColors || (channel != s.channel && channel != Broadcast && s.channel != Broadcast) {
+			//System exclusive messages and other channels are ignored
+			continue
+		}
+		err = s.show(data)
+		if err != nil {
+			return
+		}
+	}
+}
+
+//show applies the pixels in a set pixel colors message and renders them.
+//Pixels past the end of the strip are ignored, and pixels the message doesn't include keep their color.
+func (s *Server) show(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isClosed() {
+		return ErrServerClosed
+	}
+	for i := 0; i < len(s.frame) && i*3+2 < len(data); i++ {
+		s.frame[i] = ledcolor.Color{R: data[i*3], G: data[i*3+1], B: data[i*3+2]}.Grb()
+	}
+	err := s.leds.Fill(s.frame)
+	if err != nil {
+		return fmt.Errorf("could not show OPC frame: %v", err)
+	}
+	return s.leds.Render()
+}
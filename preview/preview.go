@@ -0,0 +1,269 @@
+//Package preview shows a live picture of the map in a web browser.
+//A Preview wraps the ColorFiller that drives the real LEDs. Everything is passed through to it unchanged,
+//and every rendered frame is also pushed to connected browsers with server-sent events.
+//The page draws each LED as a dot at the geographic position of the city it stands for.
+package preview
+
+import (
+	"encoding/json"
+	"fmt"
+	"led-map/api/weathermapapi"
+	"led-map/ledcolor"
+	"led-map/ledmap"
+	"net/http"
+	"sync"
+)
+
+//Preview is a ColorFiller decorator that serves a live view of the LEDs over HTTP.
+type Preview struct {
+	leds      ledmap.ColorFiller
+	locations []weathermapapi.Location
+
+	mu       sync.Mutex
+	pending  []int
+	rendered []byte //last rendered frame, already encoded for the event stream
+	clients  map[chan []byte]struct{}
+	mux      *http.ServeMux
+}
+
+//frame is the message sent to browsers on every render.
+type frame struct {
+	Colors []string `json:"colors"`
+}
+
+//New wraps leds, which must have ledCount LEDs, in a Preview.
+//The locations give the position of each LED, in LED order. LEDs without a location are drawn in a row below the map.
+//Example:
+//	p := preview.New(strip, 100, locations)
+//	go http.ListenAndServe(":8080", p)
+//	weathermap, err := ledmap.New(ledmap.LEDs(p))
+func New(leds ledmap.ColorFiller, ledCount int, locations []weathermapapi.Location) *Preview {
+	p := &Preview{
+		leds:      leds,
+		locations: locations,
+		pending:   make([]int, ledCount),
+		clients:   make(map[chan []byte]struct{}),
+		mux:       http.NewServeMux(),
+	}
+	p.rendered = p.encode()
+	p.mux.HandleFunc("/", p.pageHandler)
+	p.mux.HandleFunc("/locations", p.locationsHandler)
+	p.mux.HandleFunc("/events", p.eventsHandler)
+	return p
+}
+
+//FillSingle applies a color to all LEDs on the map.
+func (p *Preview) FillSingle(color int) error {
+	err := p.leds.FillSingle(color)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.pending {
+		p.pending[i] = color
+	}
+	return nil
+}
+
+//Fill applies an array of colors to all LEDs on the map.
+func (p *Preview) Fill(colors []int) error {
+	err := p.leds.Fill(colors)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	copy(p.pending, colors)
+	return nil
+}
+
+//Set sets a single LED's color.
+func (p *Preview) Set(index int, color int) error {
+	err := p.leds.Set(index, color)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if index < len(p.pending) {
+		p.pending[index] = color
+	}
+	return nil
+}
+
+//Render renders the wrapped LEDs, then sends the new frame to every connected browser.
+//Frames are not sent if the wrapped LEDs fail to render, so the page never shows something the map doesn't.
+func (p *Preview) Render() error {
+	err := p.leds.Render()
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rendered = p.encode()
+	for client := range p.clients {
+		send(client, p.rendered)
+	}
+	return nil
+}
+
+//send hands a frame to a client without blocking. A client that hasn't read the previous frame yet
+//only gets the newest one, so a slow browser can never hold up the map.
+func send(client chan []byte, message []byte) {
+	select {
+	case client <- message:
+		return
+	default:
+	}
+	select {
+	case <-client:
+	default:
+	}
+	client <- message
+}
+
+//encode converts the pending colors into an event stream message. The caller must hold the lock.
+func (p *Preview) encode() []byte {
+	f := frame{Colors: make([]string, len(p.pending))}
+	for i, color := range p.pending {
+		c := ledcolor.FromGrb(color)
+		f.Colors[i] = fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	message, _ := json.Marshal(f)
+	return message
+}
+
+//ServeHTTP serves the preview page at /, the LED locations at /locations, and the frame stream at /events.
+func (p *Preview) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
+}
+
+func (p *Preview) pageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, page)
+}
+
+func (p *Preview) locationsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p.locations)
+}
+
+func (p *Preview) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	client := make(chan []byte, 1)
+	p.mu.Lock()
+	p.clients[client] = struct{}{}
+	//Start every browser off with whatever the map is showing right now
+	client <- p.rendered
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.clients, client)
+		p.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case message := <-client:
+			_, err := fmt.Fprintf(w, "data: %s\n\n", message)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>led-map preview</title>
+<style>
+	body { margin: 0; background: #111; color: #ccc; font-family: sans-serif; }
+	svg { display: block; width: 100vw; height: 95vh; }
+	#status { padding: 0 1em; font-size: 0.8em; }
+	circle { stroke: #333; stroke-width: 0.5; }
+</style>
+</head>
+<body>
+<svg id="map" viewBox="0 0 1000 1000" preserveAspectRatio="xMidYMid meet"></svg>
+<div id="status">connecting...</div>
+<script>
+const svg = document.getElementById("map");
+const status = document.getElementById("status");
+const size = 1000, margin = 60, radius = 12;
+let dots = [];
+
+//Places every LED on the page. LEDs with a location are projected onto the map,
+//and any LEDs past the end of the locations are lined up along the bottom.
+function layout(locations, count) {
+	const lats = locations.map(l => l.latitude), lons = locations.map(l => l.longitude);
+	const minLat = Math.min(...lats), maxLat = Math.max(...lats);
+	const minLon = Math.min(...lons), maxLon = Math.max(...lons);
+	//Longitude degrees shrink towards the poles, so squash x to keep the map's shape
+	const squash = Math.cos((minLat + maxLat) / 2 * Math.PI / 180);
+	const width = Math.max((maxLon - minLon) * squash, 1e-6), height = Math.max(maxLat - minLat, 1e-6);
+	const scale = (size - 2 * margin - 3 * radius) / Math.max(width, height);
+	svg.innerHTML = "";
+	dots = [];
+	for (let i = 0; i < count; i++) {
+		let x, y, title;
+		if (i < locations.length) {
+			const l = locations[i];
+			x = margin + (l.longitude - minLon) * squash * scale;
+			y = margin + (maxLat - l.latitude) * scale;
+			title = i + ": " + l.city + ", " + l.state;
+		} else {
+			const extra = i - locations.length;
+			x = margin + (extra % 35) * radius * 2.5;
+			y = size - margin + Math.floor(extra / 35) * radius * 2.5;
+			title = i + ": no location";
+		}
+		const dot = document.createElementNS("http://www.w3.org/2000/svg", "circle");
+		dot.setAttribute("cx", x);
+		dot.setAttribute("cy", y);
+		dot.setAttribute("r", radius);
+		dot.setAttribute("fill", "#000");
+		const tooltip = document.createElementNS("http://www.w3.org/2000/svg", "title");
+		tooltip.textContent = title;
+		dot.appendChild(tooltip);
+		svg.appendChild(dot);
+		dots.push(dot);
+	}
+}
+
+fetch("locations").then(r => r.json()).then(locations => {
+	locations = locations || [];
+	const events = new EventSource("events");
+	let frames = 0;
+	events.onmessage = e => {
+		const colors = JSON.parse(e.data).colors;
+		if (dots.length !== colors.length) {
+			layout(locations, colors.length);
+		}
+		colors.forEach((color, i) => dots[i].setAttribute("fill", color));
+		frames++;
+		status.textContent = "live, " + frames + " frames received";
+	};
+	events.onerror = () => { status.textContent = "disconnected, retrying..."; };
+});
+</script>
+</body>
+</html>
+`
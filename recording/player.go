@@ -0,0 +1,145 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"led-map/ledmap"
+	"sync"
+	"time"
+)
+
+//Player reads a recording back, one frame at a time.
+type Player struct {
+	in       *bufio.Reader
+	frame    []int
+	played   int //frames read so far
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+//NewPlayer reads the header of the recording in in, and returns a Player positioned at its first frame.
+//The recording must have been made with ledCount LEDs. That is checked before anything is allocated,
+//so a corrupt header can't make the Player allocate more than the map needs.
+//Example:
+//	file, err := os.Open("storm.ledrec")
+//	player, err := recording.NewPlayer(file, 100)
+//	err = player.Play(strip, 2) //twice as fast as it was recorded
+func NewPlayer(in io.Reader, ledCount int) (*Player, error) {
+	reader := bufio.NewReader(in)
+	header := make([]byte, len(magic)+1)
+	_, err := io.ReadFull(reader, header)
+	if err != nil || string(header[:len(magic)]) != magic {
+		return &Player{}, fmt.Errorf("not an LED recording")
+	}
+	if header[len(magic)] != Version {
+		return &Player{}, fmt.Errorf("unsupported recording version %v, expected %v", header[len(magic)], Version)
+	}
+	recorded, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return &Player{}, fmt.Errorf("could not read LED count: %v", err)
+	}
+	if recorded != uint64(ledCount) {
+		return &Player{}, fmt.Errorf("recorded with %v LEDs, but the map has %v", recorded, ledCount)
+	}
+	return &Player{
+		in:    reader,
+		frame: make([]int, ledCount),
+		stop:  make(chan struct{}),
+	}, nil
+}
+
+//LedCount returns the number of LEDs the recording was made with.
+func (p *Player) LedCount() int {
+	return len(p.frame)
+}
+
+//Played returns the number of frames read so far.
+func (p *Player) Played() int {
+	return p.played
+}
+
+//Next reads the next frame, and returns how long after the previous frame it was rendered.
+//The returned colors are only valid until the next call. At the end of the recording, the error is io.EOF.
+func (p *Player) Next() (time.Duration, []int, error) {
+	elapsed, err := binary.ReadUvarint(p.in)
+	if err != nil {
+		return 0, nil, err
+	}
+	changed, err := binary.ReadUvarint(p.in)
+	if err != nil {
+		return 0, nil, truncated(err)
+	}
+	index := -1
+	color := make([]byte, 3)
+	for i := uint64(0); i < changed; i++ {
+		gap, err := binary.ReadUvarint(p.in)
+		if err != nil {
+			return 0, nil, truncated(err)
+		}
+		index += int(gap) + 1
+		if index < 0 || index >= len(p.frame) {
+			return 0, nil, fmt.Errorf("recording changes LED %v, but only has %v LEDs", index, len(p.frame))
+		}
+		_, err = io.ReadFull(p.in, color)
+		if err != nil {
+			return 0, nil, truncated(err)
+		}
+		p.frame[index] = int(color[0])<<16 | int(color[1])<<8 | int(color[2])
+	}
+	p.played++
+	return time.Duration(elapsed) * time.Microsecond, p.frame, nil
+}
+
+//truncated turns reaching the end of the file in the middle of a frame into an error of its own.
+func truncated(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+//Play shows every remaining frame on leds, which must have as many LEDs as the recording.
+//Speed scales time: 1 plays at the speed the recording was made, 2 twice as fast, and 0.5 at half speed.
+//Play returns nil at the end of the recording, or once Stop is called.
+func (p *Player) Play(leds ledmap.ColorFiller, speed float64) error {
+	if speed <= 0 {
+		return fmt.Errorf("speed must be positive, got: %v", speed)
+	}
+	start := time.Now()
+	var position time.Duration
+	for {
+		elapsed, frame, err := p.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		//Frames are timed from the start of playback, so slow renders don't add up to drift
+		position += time.Duration(float64(elapsed) / speed)
+		timer := time.NewTimer(time.Until(start.Add(position)))
+		select {
+		case <-p.stop:
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		err = leds.Fill(frame)
+		if err != nil {
+			return err
+		}
+		err = leds.Render()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+//Stop makes Play return before its next frame. A stopped Player can't be played again.
+func (p *Player) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+}
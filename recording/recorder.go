@@ -0,0 +1,152 @@
+package recording
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"led-map/ledmap"
+	"sync"
+	"time"
+)
+
+//Recorder is a ColorFiller decorator that records every frame it renders.
+//Everything is passed through to the wrapped LEDs unchanged.
+type Recorder struct {
+	leds ledmap.ColorFiller
+
+	mu       sync.Mutex
+	out      *bufio.Writer
+	pending  []int
+	recorded []int //last frame written to the recording
+	last     time.Time
+	now      func() time.Time
+	err      error //first error writing the recording, after which nothing more is written
+}
+
+//Function used to set options
+type option func(*Recorder)
+
+//NewRecorder wraps leds, which must have ledCount LEDs, in a Recorder that writes to out.
+//The header is written straight away. The wrapped LEDs should not autoFill, since only frames rendered
+//through the Recorder are recorded.
+//Example:
+//	file, err := os.Create("storm.ledrec")
+//	recorder, err := recording.NewRecorder(strip, 100, file)
+//	weathermap, err := ledmap.New(ledmap.LEDs(recorder))
+func NewRecorder(leds ledmap.ColorFiller, ledCount int, out io.Writer, opts ...option) (*Recorder, error) {
+	r := &Recorder{
+		leds:     leds,
+		out:      bufio.NewWriter(out),
+		pending:  make([]int, ledCount),
+		recorded: make([]int, ledCount),
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.last = r.now()
+	r.out.WriteString(magic)
+	r.out.WriteByte(Version)
+	writeUvarint(r.out, uint64(ledCount))
+	err := r.out.Flush()
+	if err != nil {
+		return &Recorder{}, err
+	}
+	return r, nil
+}
+
+//Clock provides an option for replacing the function used to timestamp frames.
+//This is useful when a test needs predictable timestamps.
+func Clock(now func() time.Time) option {
+	return func(r *Recorder) {
+		r.now = now
+	}
+}
+
+//FillSingle applies a color to all LEDs.
+func (r *Recorder) FillSingle(color int) error {
+	err := r.leds.FillSingle(color)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.pending {
+		r.pending[i] = color
+	}
+	return nil
+}
+
+//Fill applies an array of colors to all LEDs.
+func (r *Recorder) Fill(colors []int) error {
+	err := r.leds.Fill(colors)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copy(r.pending, colors)
+	return nil
+}
+
+//Set sets a single LED's color.
+func (r *Recorder) Set(index int, color int) error {
+	err := r.leds.Set(index, color)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if index < len(r.pending) {
+		r.pending[index] = color
+	}
+	return nil
+}
+
+//Render renders the wrapped LEDs, then records the frame.
+//Frames are not recorded if the wrapped LEDs fail to render, so the recording only holds what was really shown.
+func (r *Recorder) Render() error {
+	err := r.leds.Render()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.record()
+}
+
+//record writes the pending colors as the next frame, and flushes it so the file is complete even if the map is killed.
+//The caller must hold the lock.
+func (r *Recorder) record() error {
+	if r.err != nil {
+		return r.err
+	}
+	now := r.now()
+	elapsed := now.Sub(r.last)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	r.last = now
+
+	changed := make([]int, 0)
+	for i, color := range r.pending {
+		if color&0xffffff != r.recorded[i] {
+			changed = append(changed, i)
+		}
+	}
+	writeUvarint(r.out, uint64(elapsed/time.Microsecond))
+	writeUvarint(r.out, uint64(len(changed)))
+	previous := -1
+	for _, index := range changed {
+		color := r.pending[index]
+		writeUvarint(r.out, uint64(index-previous-1))
+		r.out.Write([]byte{byte(color >> 16), byte(color >> 8), byte(color)})
+		r.recorded[index] = color & 0xffffff
+		previous = index
+	}
+	err := r.out.Flush()
+	if err != nil {
+		r.err = fmt.Errorf("could not record frame: %v", err)
+	}
+	return r.err
+}
@@ -0,0 +1,33 @@
+//Package recording captures the frames a map renders into a compact file, and plays them back later.
+//A Recorder wraps the ColorFiller that drives the LEDs and writes every rendered frame to the file.
+//A Player reads the file back and shows it on any strip, at the speed it was recorded or faster or slower,
+//so an interesting forecast can be shown at a demo, or checked frame by frame in a regression test.
+//
+//A recording starts with a header: the magic bytes "LEDREC", a version byte and the LED count as a uvarint.
+//It is followed by one entry per frame:
+//	uvarint microseconds since the previous frame, or since recording started for the first one
+//	uvarint number of LEDs that changed since the previous frame
+//	for every changed LED, in index order:
+//		uvarint gap since the previous changed LED, which is the index itself for the first one
+//		3 bytes of color, packed the way utilities.HsvToGrb packs it, most significant byte first
+//Every LED is off before the first frame. Frames that change nothing still take two bytes, so holds keep their timing.
+package recording
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+//Version is the version of the file format written by a Recorder.
+const Version = 1
+
+//magic starts every recording.
+const magic = "LEDREC"
+
+//writeUvarint writes a uvarint to w.
+func writeUvarint(w io.Writer, value uint64) error {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, value)
+	_, err := w.Write(buf[:n])
+	return err
+}
@@ -0,0 +1,213 @@
+package recording
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"led-map/virtualstrip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//steppingClock returns a clock that reads start first, then moves on by each of steps in turn.
+func steppingClock(start time.Time, steps ...time.Duration) func() time.Time {
+	now := start
+	return func() time.Time {
+		current := now
+		if len(steps) > 0 {
+			now = now.Add(steps[0])
+			steps = steps[1:]
+		}
+		return current
+	}
+}
+
+//recorded is a frame as it should be played back.
+type recorded struct {
+	elapsed time.Duration
+	colors  []int
+}
+
+//record records frames of three LEDs with a fixed clock, and returns the recording, what it should play back,
+//and where every frame ends in the recording.
+func record(t *testing.T) ([]byte, []recorded, []int) {
+	t.Helper()
+	var file bytes.Buffer
+	strip := virtualstrip.New(3, false)
+	clock := steppingClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), 10*time.Millisecond, 3*time.Second, 1500*time.Microsecond, 0)
+	r, err := NewRecorder(strip, 3, &file, Clock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []recorded{
+		{10 * time.Millisecond, []int{0x102030, 0, 0xffffff}},
+		{3 * time.Second, []int{0x102030, 0, 0xffffff}}, //nothing changed, but the hold is kept
+		{1500 * time.Microsecond, []int{0x102030, 0x0000ff, 0xffffff}},
+		{0, []int{0x000001, 0x000001, 0x000001}},
+	}
+	var ends []int
+	steps := []func() error{
+		func() error { return r.Fill(want[0].colors) },
+		func() error { return nil },
+		func() error { return r.Set(1, 0x0000ff) },
+		func() error { return r.FillSingle(0x000001) },
+	}
+	for i, step := range steps {
+		err = step()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = r.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ends = append(ends, file.Len())
+		if got := strip.Rendered(); !reflect.DeepEqual(got, want[i].colors) {
+			t.Fatalf("frame %v reached the LEDs as %x, want %x", i, got, want[i].colors)
+		}
+	}
+	return file.Bytes(), want, ends
+}
+
+func TestRoundTrip(t *testing.T) {
+	data, want, _ := record(t)
+	p, err := NewPlayer(bytes.NewReader(data), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.LedCount() != 3 {
+		t.Errorf("LedCount() = %v, want 3", p.LedCount())
+	}
+	for i, frame := range want {
+		elapsed, colors, err := p.Next()
+		if err != nil {
+			t.Fatalf("frame %v: %v", i, err)
+		}
+		if elapsed != frame.elapsed {
+			t.Errorf("frame %v came %v after the last, want %v", i, elapsed, frame.elapsed)
+		}
+		if !reflect.DeepEqual(colors, frame.colors) {
+			t.Errorf("frame %v = %x, want %x", i, colors, frame.colors)
+		}
+	}
+	if _, _, err := p.Next(); err != io.EOF {
+		t.Errorf("reading past the last frame returned %v, want io.EOF", err)
+	}
+	if p.Played() != len(want) {
+		t.Errorf("Played() = %v, want %v", p.Played(), len(want))
+	}
+}
+
+func TestPlay(t *testing.T) {
+	data, want, _ := record(t)
+	p, err := NewPlayer(bytes.NewReader(data), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	strip := virtualstrip.New(3, false)
+	start := time.Now()
+	//At 100 times the speed, the 3 second hold takes 30ms
+	err = p.Play(strip, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(start); took < 30*time.Millisecond {
+		t.Errorf("playing at 100 times the speed took %v, want at least 30ms", took)
+	}
+	frames := strip.Frames()
+	if len(frames) != len(want) {
+		t.Fatalf("played %v frames, want %v", len(frames), len(want))
+	}
+	for i, frame := range frames {
+		if !reflect.DeepEqual(frame.Colors, want[i].colors) {
+			t.Errorf("frame %v = %x, want %x", i, frame.Colors, want[i].colors)
+		}
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	data, _, ends := record(t)
+	header := len(magic) + 2 //magic, version and a one byte LED count
+	for cut := header; cut < len(data); cut++ {
+		p, err := NewPlayer(bytes.NewReader(data[:cut]), 3)
+		if err != nil {
+			t.Fatalf("header cut at %v: %v", cut, err)
+		}
+		complete := 0
+		for complete < len(ends) && ends[complete] <= cut {
+			complete++
+		}
+		for i := 0; i < complete; i++ {
+			_, _, err := p.Next()
+			if err != nil {
+				t.Fatalf("cut at %v: complete frame %v returned %v", cut, i, err)
+			}
+		}
+		_, _, err = p.Next()
+		atBoundary := cut == header || (complete > 0 && ends[complete-1] == cut)
+		if atBoundary && err != io.EOF {
+			t.Errorf("cut between frames at %v returned %v, want io.EOF", cut, err)
+		}
+		if !atBoundary && err != io.ErrUnexpectedEOF {
+			t.Errorf("cut in the middle of a frame at %v returned %v, want io.ErrUnexpectedEOF", cut, err)
+		}
+	}
+}
+
+func TestBadHeader(t *testing.T) {
+	data, _, _ := record(t)
+	tests := []struct {
+		name     string
+		data     []byte
+		ledCount int
+	}{
+		{"empty", nil, 3},
+		{"not a recording", []byte("GIF89a..."), 3},
+		{"other version", append([]byte(magic), Version+1, 3), 3},
+		{"no LED count", []byte(magic + string(rune(Version))), 3},
+		{"other LED count", data, 4},
+		{"huge LED count", append([]byte(magic), Version, 0xff, 0xff, 0xff, 0xff, 0x0f), 3},
+	}
+	for _, test := range tests {
+		if _, err := NewPlayer(bytes.NewReader(test.data), test.ledCount); err == nil {
+			t.Errorf("%v: recording was accepted", test.name)
+		}
+	}
+}
+
+func TestOutOfBoundsLed(t *testing.T) {
+	//One frame that changes LED 5 of 3
+	data := append([]byte(magic), Version, 3, 0, 1, 5, 0xff, 0xff, 0xff)
+	p, err := NewPlayer(bytes.NewReader(data), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.Next(); err == nil {
+		t.Error("frame changing an LED past the end was accepted")
+	}
+}
+
+//failingStrip is a virtual strip whose renders fail.
+type failingStrip struct {
+	*virtualstrip.VirtualStrip
+}
+
+func (f failingStrip) Render() error {
+	return fmt.Errorf("DMA error")
+}
+
+func TestFailedRendersArentRecorded(t *testing.T) {
+	var file bytes.Buffer
+	r, err := NewRecorder(failingStrip{virtualstrip.New(1, false)}, 1, &file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := file.Len()
+	if err := r.Render(); err == nil {
+		t.Fatal("render error was dropped")
+	}
+	if file.Len() != header {
+		t.Errorf("a failed render was recorded, %v bytes were written", file.Len()-header)
+	}
+}
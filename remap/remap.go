@@ -0,0 +1,258 @@
+//Package remap fixes hardware quirks of a map in software.
+//A Remapper sits between LedMap and the strip. LedMap keeps using logical indexes, the ones defaultLocations.json
+//uses, and the Remapper moves every color to the physical LED that really stands for that location.
+//LEDs that were soldered in the wrong order, swapped, or skipped when a dead LED was bridged over can be put
+//right without resoldering, and LEDs that are dead or flicker can be kept dark or made to copy a neighbor.
+package remap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"led-map/ledmap"
+	"sync"
+)
+
+//Table describes how logical LEDs are laid out on the physical strip.
+//Every index is logical, except the values of Map, which are physical. The zero value changes nothing.
+type Table struct {
+	//LedCount is the number of physical LEDs on the strip. 0 means there are as many as there are logical LEDs.
+	LedCount int `json:"ledCount"`
+	//Map gives the physical LED of logical LEDs that aren't where their index says. The rest keep their index.
+	//Physical LEDs that no logical LED is mapped to are kept dark.
+	Map map[int]int `json:"map"`
+	//Mask lists logical LEDs whose own color is never shown, such as ones that are dead, stuck or flickering.
+	//They are kept dark, unless Substitute gives them another LED's color to show instead.
+	Mask []int `json:"mask"`
+	//Bypassed lists logical LEDs that were cut out of the strip, with their data line bridged over.
+	//They are never lit, so they can't be mapped or substituted, and every LED after one of them moves down a physical index.
+	Bypassed []int `json:"bypassed"`
+	//Substitute makes logical LEDs, masked or not, show another logical LED's color instead of their own.
+	Substitute map[int]int `json:"substitute"`
+}
+
+//Load reads a table from a JSON file, and validates it for a map of ledCount logical LEDs.
+//Example file, for a strip where LEDs 3 and 4 are swapped, LED 17 is dead, LED 42 flickers and copies its
+//neighbor instead, and LED 60 was cut out:
+//	{"map": {"3": 4, "4": 3}, "mask": [17, 42], "substitute": {"42": 41}, "bypassed": [60]}
+func Load(path string, ledCount int) (Table, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Table{}, err
+	}
+	t := Table{}
+	err = json.Unmarshal(data, &t)
+	if err != nil {
+		return Table{}, fmt.Errorf("could not read remapping table from %v: %v", path, err)
+	}
+	err = t.Validate(ledCount)
+	if err != nil {
+		return Table{}, fmt.Errorf("%v: %v", path, err)
+	}
+	return t, nil
+}
+
+//PhysicalCount returns the number of physical LEDs the table needs for ledCount logical LEDs.
+func (t Table) PhysicalCount(ledCount int) int {
+	if t.LedCount == 0 {
+		return ledCount - len(t.Bypassed)
+	}
+	return t.LedCount
+}
+
+//Validate checks that the table fits a map of ledCount logical LEDs, and that no two logical LEDs share a physical one.
+func (t Table) Validate(ledCount int) error {
+	_, err := t.compile(ledCount)
+	return err
+}
+
+//compile works out the physical LED and the color source of every logical LED.
+func (t Table) compile(ledCount int) (table, error) {
+	inRange := func(name string, index int, count int) error {
+		if index < 0 || index >= count {
+			return fmt.Errorf("%v %v is out of bounds, there are %v", name, index, count)
+		}
+		return nil
+	}
+	bypassed := make(map[int]bool, len(t.Bypassed))
+	for _, logical := range t.Bypassed {
+		err := inRange("bypassed LED", logical, ledCount)
+		if err != nil {
+			return table{}, err
+		}
+		if bypassed[logical] {
+			return table{}, fmt.Errorf("LED %v is listed as bypassed more than once", logical)
+		}
+		bypassed[logical] = true
+	}
+	physicalCount := t.PhysicalCount(ledCount)
+	if physicalCount < 0 {
+		return table{}, fmt.Errorf("LED count can't be negative, got: %v", t.LedCount)
+	}
+
+	compiled := table{
+		physical: make([]int, ledCount),
+		source:   make([]int, ledCount),
+		count:    physicalCount,
+	}
+	shift := 0
+	for i := range compiled.physical {
+		compiled.source[i] = i
+		if bypassed[i] {
+			compiled.physical[i] = masked
+			shift++
+			continue
+		}
+		compiled.physical[i] = i - shift
+	}
+	for logical, physical := range t.Map {
+		err := inRange("mapped logical LED", logical, ledCount)
+		if err == nil {
+			err = inRange("physical LED", physical, physicalCount)
+		}
+		if err != nil {
+			return table{}, err
+		}
+		if bypassed[logical] {
+			return table{}, fmt.Errorf("LED %v is bypassed, so it can't be mapped to physical LED %v", logical, physical)
+		}
+		compiled.physical[logical] = physical
+	}
+	for _, logical := range t.Mask {
+		err := inRange("masked LED", logical, ledCount)
+		if err != nil {
+			return table{}, err
+		}
+		//A masked LED with a substitute keeps its physical LED to show the substitute on
+		if _, substituted := t.Substitute[logical]; !substituted {
+			compiled.physical[logical] = masked
+		}
+	}
+	for logical, source := range t.Substitute {
+		err := inRange("substituted LED", logical, ledCount)
+		if err == nil {
+			err = inRange("substitute LED", source, ledCount)
+		}
+		if err != nil {
+			return table{}, err
+		}
+		if bypassed[logical] {
+			return table{}, fmt.Errorf("LED %v is bypassed, so it has no physical LED to show a substitute on", logical)
+		}
+		compiled.source[logical] = source
+	}
+
+	used := make(map[int]int)
+	for logical, physical := range compiled.physical {
+		if physical == masked {
+			continue
+		}
+		if physical >= physicalCount {
+			return table{}, fmt.Errorf("logical LED %v has no physical LED, map it to one of the %v physical LEDs or mask it", logical, physicalCount)
+		}
+		if other, ok := used[physical]; ok {
+			return table{}, fmt.Errorf("logical LEDs %v and %v are both on physical LED %v", other, logical, physical)
+		}
+		used[physical] = logical
+	}
+	return compiled, nil
+}
+
+//masked is the physical index of a logical LED that isn't shown.
+const masked = -1
+
+//table is a Table worked out for a particular number of logical LEDs.
+type table struct {
+	physical []int //physical LED of each logical LED, or masked
+	source   []int //logical LED whose color each logical LED shows
+	count    int   //number of physical LEDs
+}
+
+//Remapper is a ColorFiller decorator that moves logical LEDs to their physical LEDs.
+type Remapper struct {
+	leds  ledmap.ColorFiller
+	table table
+
+	mu      sync.Mutex
+	pending []int //logical colors
+	frame   []int //physical colors
+}
+
+//New wraps leds, which must have as many LEDs as the table's PhysicalCount, in a Remapper for ledCount logical LEDs.
+//Example:
+//	table, err := remap.Load("remap.json", 100)
+//	strip, err := ledstrip.Init(table.PhysicalCount(100), 255, false)
+//	leds, err := remap.New(strip, 100, table)
+func New(leds ledmap.ColorFiller, ledCount int, t Table) (*Remapper, error) {
+	compiled, err := t.compile(ledCount)
+	if err != nil {
+		return &Remapper{}, err
+	}
+	return &Remapper{
+		leds:    leds,
+		table:   compiled,
+		pending: make([]int, ledCount),
+		frame:   make([]int, compiled.count),
+	}, nil
+}
+
+//FillSingle applies a color to all logical LEDs. Masked and unused physical LEDs stay dark.
+func (r *Remapper) FillSingle(color int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.pending {
+		r.pending[i] = color
+	}
+	return r.fill()
+}
+
+//Fill applies an array of colors to all logical LEDs. The array of colors must be the same length as the map.
+func (r *Remapper) Fill(colors []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(colors) != len(r.pending) {
+		return fmt.Errorf("mismatch between number of colors and number of LEDs. colors = %v, LEDs = %v", len(colors), len(r.pending))
+	}
+	copy(r.pending, colors)
+	return r.fill()
+}
+
+//fill lays the logical colors out on the physical LEDs and hands them to the wrapped LEDs. The caller must hold the lock.
+func (r *Remapper) fill() error {
+	for i := range r.frame {
+		r.frame[i] = 0
+	}
+	for logical, physical := range r.table.physical {
+		if physical != masked {
+			r.frame[physical] = r.pending[r.table.source[logical]]
+		}
+	}
+	return r.leds.Fill(r.frame)
+}
+
+//Set sets a single logical LED's color, along with every LED that shows it as a substitute.
+func (r *Remapper) Set(index int, color int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if index >= len(r.pending) || index < 0 {
+		return fmt.Errorf("index is out of bounds")
+	}
+	r.pending[index] = color
+	for logical, source := range r.table.source {
+		physical := r.table.physical[logical]
+		if source != index || physical == masked {
+			continue
+		}
+		r.frame[physical] = color
+		err := r.leds.Set(physical, color)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Render renders the wrapped LEDs.
+func (r *Remapper) Render() error {
+	return r.leds.Render()
+}
@@ -0,0 +1,108 @@
+package remap
+
+import (
+	"led-map/virtualstrip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name     string
+		table    Table
+		physical []int
+		source   []int
+		count    int
+	}{
+		{"nothing", Table{}, []int{0, 1, 2, 3}, []int{0, 1, 2, 3}, 4},
+		{"swap", Table{Map: map[int]int{1: 2, 2: 1}}, []int{0, 2, 1, 3}, []int{0, 1, 2, 3}, 4},
+		{"mask", Table{Mask: []int{2}}, []int{0, 1, masked, 3}, []int{0, 1, 2, 3}, 4},
+		{"substitute", Table{Substitute: map[int]int{3: 0}}, []int{0, 1, 2, 3}, []int{0, 1, 2, 0}, 4},
+		{"masked with a substitute", Table{Mask: []int{1}, Substitute: map[int]int{1: 2}}, []int{0, 1, 2, 3}, []int{0, 2, 2, 3}, 4},
+		{"bypass", Table{Bypassed: []int{1}}, []int{0, masked, 1, 2}, []int{0, 1, 2, 3}, 3},
+		{"bypass and swap", Table{Bypassed: []int{0}, Map: map[int]int{2: 2, 3: 1}}, []int{masked, 0, 2, 1}, []int{0, 1, 2, 3}, 3},
+		{"substitute from a bypassed LED", Table{Bypassed: []int{3}, Substitute: map[int]int{2: 3}}, []int{0, 1, 2, masked}, []int{0, 1, 3, 3}, 3},
+		{"longer strip", Table{LedCount: 6, Map: map[int]int{3: 5}}, []int{0, 1, 2, 5}, []int{0, 1, 2, 3}, 6},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			compiled, err := test.table.compile(4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(compiled.physical, test.physical) {
+				t.Errorf("physical LEDs = %v, want %v", compiled.physical, test.physical)
+			}
+			if !reflect.DeepEqual(compiled.source, test.source) {
+				t.Errorf("color sources = %v, want %v", compiled.source, test.source)
+			}
+			if compiled.count != test.count || test.table.PhysicalCount(4) != test.count {
+				t.Errorf("physical count = %v, want %v", compiled.count, test.count)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		table Table
+		want  string
+	}{
+		{"negative LED count", Table{LedCount: -1}, "negative"},
+		{"mapped out of bounds", Table{Map: map[int]int{4: 0}}, "out of bounds"},
+		{"mapped past the strip", Table{Map: map[int]int{0: 4}}, "out of bounds"},
+		{"two LEDs on one", Table{Map: map[int]int{0: 1}}, "both on physical LED 1"},
+		{"masked out of bounds", Table{Mask: []int{-1}}, "out of bounds"},
+		{"substitute out of bounds", Table{Substitute: map[int]int{0: 9}}, "out of bounds"},
+		{"bypassed out of bounds", Table{Bypassed: []int{4}}, "out of bounds"},
+		{"bypassed twice", Table{Bypassed: []int{1, 1}}, "more than once"},
+		{"bypassed and mapped", Table{Bypassed: []int{1}, Map: map[int]int{1: 0, 0: 1}}, "can't be mapped"},
+		{"bypassed with a substitute", Table{Bypassed: []int{1}, Substitute: map[int]int{1: 0}}, "no physical LED to show a substitute on"},
+		{"too few physical LEDs", Table{LedCount: 3}, "has no physical LED"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.table.Validate(4)
+			if err == nil {
+				t.Fatal("table was accepted")
+			}
+			if !strings.Contains(err.Error(), test.want) {
+				t.Errorf("error %q should mention %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestRemapper(t *testing.T) {
+	table := Table{Map: map[int]int{0: 1, 1: 0}, Mask: []int{2}, Substitute: map[int]int{3: 0}, Bypassed: []int{4}}
+	strip := virtualstrip.New(table.PhysicalCount(5), false)
+	r, err := New(strip, 5, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Fill([]int{0x0a, 0x0b, 0x0c, 0x0d, 0x0e})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strip.Pending(), []int{0x0b, 0x0a, 0, 0x0a}; !reflect.DeepEqual(got, want) {
+		t.Errorf("filled physical LEDs = %x, want %x", got, want)
+	}
+
+	//Setting an LED also sets every LED that shows it as a substitute
+	err = r.Set(0, 0xff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strip.Rendered(), []int{0x0b, 0xff, 0, 0xff}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rendered physical LEDs = %x, want %x", got, want)
+	}
+	if r.Set(5, 0) == nil || r.Fill(make([]int, 4)) == nil {
+		t.Error("out of bounds writes were accepted")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"led-map/ledcolor"
+	"led-map/ledmap"
+	"led-map/supervisor"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+//defaultFadeOut is how long the map takes to fade out when it is shut down, unless LED_FADE_OUT sets another time.
+const defaultFadeOut = time.Second
+
+//onSignal calls stop when the process is asked to quit with SIGINT or SIGTERM.
+//A second signal quits straight away, in case shutting down hangs.
+func onSignal(stop func()) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		log.Printf("received %v, shutting down", sig)
+		stop()
+		<-signals
+		log.Print("received a second signal, quitting without shutting down")
+		os.Exit(1)
+	}()
+}
+
+//shutdown takes the LEDs down gracefully, and returns the status the process should exit with.
+//The LEDs fade from the last frame they rendered to the LED_PARTING_COLOR color, black unless it is set, over
+//LED_FADE_OUT. The fade is drawn on via, which is leds or a ColorFiller wrapping it, so previews and recordings
+//show it too. Finally, the strip is shut down.
+func shutdown(leds *supervisor.Supervisor, via ledmap.ColorFiller) int {
+	defer leds.Deinit()
+	color, duration, err := partingOptions()
+	if err != nil {
+		log.Print(err)
+		color, duration = 0, defaultFadeOut
+	}
+	err = ledmap.Fade(via, leds.Rendered(), color, duration)
+	if err != nil {
+		log.Printf("could not take the LEDs down: %v", err)
+		return 1
+	}
+	return 0
+}
+
+//partingOptions reads the parting color from LED_PARTING_COLOR, written like #ff8000, and the fade out time from LED_FADE_OUT.
+func partingOptions() (int, time.Duration, error) {
+	color := 0
+	if s := os.Getenv("LED_PARTING_COLOR"); s != "" {
+		rgb, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 24)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid LED_PARTING_COLOR %q, expected a color like #ff8000", s)
+		}
+		color = ledcolor.Color{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb)}.Grb()
+	}
+	duration := defaultFadeOut
+	if s := os.Getenv("LED_FADE_OUT"); s != "" {
+		var err error
+		duration, err = time.ParseDuration(s)
+		if err != nil || duration < 0 {
+			return 0, 0, fmt.Errorf("invalid LED_FADE_OUT %q, expected a duration like 2s", s)
+		}
+	}
+	return color, duration, nil
+}
@@ -0,0 +1,292 @@
+//Package supervisor keeps a map running when its LEDs fail.
+//A Supervisor wraps a strip and watches its renders. After a few failed renders in a row it shuts the strip down,
+//and keeps opening it again in the background, waiting longer after every attempt that fails. Once the strip is
+//back, the last frame is restored. The map keeps drawing the whole time, so it runs unattended for months.
+package supervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"led-map/ledmap"
+	"sync"
+	"time"
+)
+
+//Strip is an LED strip that the map can draw on and that can be shut down.
+type Strip interface {
+	ledmap.ColorFiller
+	Deinit()
+}
+
+//Defaults for the options
+const (
+	DefaultThreshold  = 3
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = 5 * time.Minute
+)
+
+//Stats reports how the supervised strip has been doing.
+type Stats struct {
+	Renders         uint64    `json:"renders"`         //renders that succeeded
+	Failures        uint64    `json:"failures"`        //renders that failed
+	Consecutive     int       `json:"consecutive"`     //renders that failed since the last one that succeeded
+	Restarts        uint64    `json:"restarts"`        //times the strip was opened again after failing
+	RestartFailures uint64    `json:"restartFailures"` //attempts to open the strip again that failed
+	Down            bool      `json:"down"`            //the strip has been shut down and is being opened again
+	LastError       error     `json:"-"`               //the last render or restart error
+	LastFailure     time.Time `json:"lastFailure"`     //when the last error happened
+}
+
+//MarshalJSON writes the stats with the last error as a string, which is empty if there hasn't been one.
+func (s Stats) MarshalJSON() ([]byte, error) {
+	type stats Stats
+	lastError := ""
+	if s.LastError != nil {
+		lastError = s.LastError.Error()
+	}
+	return json.Marshal(struct {
+		stats
+		LastError string `json:"lastError"`
+	}{stats(s), lastError})
+}
+
+//Supervisor is a ColorFiller that opens its strip again when rendering keeps failing.
+//Render errors are never returned: they are counted in Stats and passed to the OnFailure function,
+//so a map or MapController never stops over a glitch.
+type Supervisor struct {
+	open       func() (Strip, error)
+	threshold  int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	onFailure  func(error)
+
+	mu       sync.Mutex
+	leds     Strip //nil while the strip is down
+	pending  []int
+	rendered []int //last frame rendered, restored when the strip is back
+	stats    Stats
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+//Function used to set options
+type option func(*Supervisor)
+
+//New opens a strip of ledCount LEDs with open, and supervises it. Open is called again whenever the strip has to be re-initialized.
+//The strip should not autoFill, the Supervisor has to see every render.
+//Example:
+//	leds, err := supervisor.New(func() (supervisor.Strip, error) {
+//		return ledstrip.Init(100, 255, false)
+//	}, 100, supervisor.OnFailure(func(err error) { log.Print(err) }))
+func New(open func() (Strip, error), ledCount int, opts ...option) (*Supervisor, error) {
+	s := &Supervisor{
+		open:       open,
+		threshold:  DefaultThreshold,
+		minBackoff: DefaultMinBackoff,
+		maxBackoff: DefaultMaxBackoff,
+		onFailure:  func(error) {},
+		pending:    make([]int, ledCount),
+		rendered:   make([]int, ledCount),
+		stop:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.threshold < 1 {
+		return &Supervisor{}, fmt.Errorf("failure threshold must be at least 1, got: %v", s.threshold)
+	}
+	if s.minBackoff <= 0 || s.maxBackoff < s.minBackoff {
+		return &Supervisor{}, fmt.Errorf("backoff must be positive, and its maximum no shorter than its minimum, got: %v and %v", s.minBackoff, s.maxBackoff)
+	}
+	leds, err := open()
+	if err != nil {
+		return &Supervisor{}, err
+	}
+	s.leds = leds
+	return s, nil
+}
+
+//Threshold provides an option for setting how many renders in a row have to fail before the strip is re-initialized.
+func Threshold(failures int) option {
+	return func(s *Supervisor) {
+		s.threshold = failures
+	}
+}
+
+//Backoff provides an option for setting how long to wait before the first attempt to open the strip again.
+//The wait doubles after every attempt that fails, up to max.
+func Backoff(min time.Duration, max time.Duration) option {
+	return func(s *Supervisor) {
+		s.minBackoff = min
+		s.maxBackoff = max
+	}
+}
+
+//OnFailure provides an option for a function that is called with every render and restart error, for logging.
+//It must not call the Supervisor.
+func OnFailure(report func(error)) option {
+	return func(s *Supervisor) {
+		s.onFailure = report
+	}
+}
+
+//FillSingle applies a color to all LEDs.
+func (s *Supervisor) FillSingle(color int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.pending {
+		s.pending[i] = color
+	}
+	if s.leds == nil {
+		return nil
+	}
+	return s.leds.FillSingle(color)
+}
+
+//Fill applies an array of colors to all LEDs. The array of colors must be the same length as the number of LEDs.
+func (s *Supervisor) Fill(colors []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(colors) != len(s.pending) {
+		return fmt.Errorf("mismatch between number of colors and number of LEDs. colors = %v, LEDs = %v", len(colors), len(s.pending))
+	}
+	copy(s.pending, colors)
+	if s.leds == nil {
+		return nil
+	}
+	return s.leds.Fill(colors)
+}
+
+//Set sets a single LED's color.
+func (s *Supervisor) Set(index int, color int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index >= len(s.pending) || index < 0 {
+		return fmt.Errorf("index is out of bounds")
+	}
+	s.pending[index] = color
+	if s.leds == nil {
+		return nil
+	}
+	return s.leds.Set(index, color)
+}
+
+//Render renders the strip. While the strip is down, the frame is kept so it can be shown once the strip is back.
+func (s *Supervisor) Render() error {
+	s.mu.Lock()
+	copy(s.rendered, s.pending)
+	if s.leds == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	err := s.leds.Render()
+	if err == nil {
+		s.stats.Renders++
+		s.stats.Consecutive = 0
+		s.mu.Unlock()
+		return nil
+	}
+	s.stats.Failures++
+	s.stats.Consecutive++
+	s.stats.LastError = err
+	s.stats.LastFailure = time.Now()
+	err = fmt.Errorf("render failed %v times in a row: %v", s.stats.Consecutive, err)
+	if s.stats.Consecutive >= s.threshold {
+		s.leds.Deinit()
+		s.leds = nil
+		s.stats.Down = true
+		go s.restart()
+	}
+	s.mu.Unlock()
+	s.onFailure(err)
+	return nil
+}
+
+//restart keeps opening the strip until it succeeds or the Supervisor is shut down, then restores the last frame.
+func (s *Supervisor) restart() {
+	delay := s.minBackoff
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(delay):
+		}
+		err := s.reopen()
+		if err == nil {
+			return
+		}
+		delay *= 2
+		if delay > s.maxBackoff {
+			delay = s.maxBackoff
+		}
+		s.onFailure(fmt.Errorf("could not re-initialize the LEDs, trying again in %v: %v", delay, err))
+	}
+}
+
+//reopen opens the strip and restores the last frame on it, along with any changes made since.
+func (s *Supervisor) reopen() error {
+	leds, err := s.open()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		err = leds.Fill(s.rendered)
+		if err == nil {
+			err = leds.Render()
+		}
+		if err == nil {
+			err = leds.Fill(s.pending)
+		}
+		if err != nil {
+			leds.Deinit()
+		}
+	}
+	select {
+	case <-s.stop:
+		//Shut down while the strip was being opened
+		if err == nil {
+			leds.Deinit()
+		}
+		return nil
+	default:
+	}
+	if err != nil {
+		s.stats.RestartFailures++
+		s.stats.LastError = err
+		s.stats.LastFailure = time.Now()
+		return err
+	}
+	s.leds = leds
+	s.stats.Restarts++
+	s.stats.Consecutive = 0
+	s.stats.Down = false
+	return nil
+}
+
+//Rendered returns a copy of the last frame rendered, which the strip is showing, or will show once it is back.
+func (s *Supervisor) Rendered() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rendered := make([]int, len(s.rendered))
+	copy(rendered, s.rendered)
+	return rendered
+}
+
+//Stats returns the statistics so far.
+func (s *Supervisor) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+//Deinit stops any restart in progress and shuts the strip down.
+func (s *Supervisor) Deinit() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leds != nil {
+		s.leds.Deinit()
+		s.leds = nil
+	}
+}
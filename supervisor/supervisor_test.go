@@ -0,0 +1,273 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//fakeStrip is a strip in memory whose renders fail while failing is set.
+type fakeStrip struct {
+	mu       sync.Mutex
+	colors   []int
+	rendered []int //colors at the last render that succeeded
+	failing  bool
+	closed   bool
+}
+
+func newFakeStrip(ledCount int) *fakeStrip {
+	return &fakeStrip{colors: make([]int, ledCount)}
+}
+
+func (f *fakeStrip) FillSingle(color int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.colors {
+		f.colors[i] = color
+	}
+	return nil
+}
+
+func (f *fakeStrip) Fill(colors []int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	copy(f.colors, colors)
+	return nil
+}
+
+func (f *fakeStrip) Set(index int, color int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.colors[index] = color
+	return nil
+}
+
+func (f *fakeStrip) Render() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failing {
+		return fmt.Errorf("DMA error")
+	}
+	f.rendered = append([]int(nil), f.colors...)
+	return nil
+}
+
+func (f *fakeStrip) Deinit() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+}
+
+func (f *fakeStrip) setFailing(failing bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failing = failing
+}
+
+func (f *fakeStrip) lastRendered() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rendered
+}
+
+func (f *fakeStrip) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+//opener hands out a new fakeStrip every time the Supervisor opens its strip.
+type opener struct {
+	mu     sync.Mutex
+	strips []*fakeStrip
+	fail   bool
+}
+
+func (o *opener) open(ledCount int) func() (Strip, error) {
+	return func() (Strip, error) {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if o.fail {
+			return nil, fmt.Errorf("no such device")
+		}
+		strip := newFakeStrip(ledCount)
+		o.strips = append(o.strips, strip)
+		return strip, nil
+	}
+}
+
+func (o *opener) opened() []*fakeStrip {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]*fakeStrip(nil), o.strips...)
+}
+
+func (o *opener) setFail(fail bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.fail = fail
+}
+
+//waitFor polls done until it returns true, and fails the test if it doesn't within a second.
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %v", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReopensAfterThresholdAndRestoresFrame(t *testing.T) {
+	o := &opener{}
+	var failures []error
+	var failuresMu sync.Mutex
+	s, err := New(o.open(3), 3, Threshold(2), Backoff(time.Millisecond, 10*time.Millisecond), OnFailure(func(err error) {
+		failuresMu.Lock()
+		defer failuresMu.Unlock()
+		failures = append(failures, err)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Deinit()
+
+	first := o.opened()[0]
+	frame := []int{0x110000, 0x002200, 0x000033}
+	if err := s.Fill(frame); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Render(); err != nil {
+		t.Fatal(err)
+	}
+
+	first.setFailing(true)
+	if err := s.Render(); err != nil {
+		t.Fatalf("render errors should not be returned, got: %v", err)
+	}
+	if stats := s.Stats(); stats.Down || stats.Consecutive != 1 || len(o.opened()) != 1 {
+		t.Fatalf("strip should not be reopened before the threshold, stats: %+v", stats)
+	}
+	if err := s.Render(); err != nil {
+		t.Fatalf("render errors should not be returned, got: %v", err)
+	}
+	if !first.isClosed() {
+		t.Error("failed strip was not shut down")
+	}
+
+	waitFor(t, "the strip to be reopened", func() bool {
+		return s.Stats().Restarts == 1
+	})
+	opened := o.opened()
+	if len(opened) != 2 {
+		t.Fatalf("strip opened %v times, want 2", len(opened))
+	}
+	if got := opened[1].lastRendered(); !reflect.DeepEqual(got, frame) {
+		t.Errorf("reopened strip shows %x, want the last frame %x", got, frame)
+	}
+
+	stats := s.Stats()
+	if stats.Down || stats.Consecutive != 0 || stats.Renders != 1 || stats.Failures != 2 || stats.LastError == nil {
+		t.Errorf("unexpected stats after the restart: %+v", stats)
+	}
+	failuresMu.Lock()
+	if len(failures) != 2 {
+		t.Errorf("OnFailure called %v times, want 2", len(failures))
+	}
+	failuresMu.Unlock()
+
+	next := []int{0x000001, 0x000002, 0x000003}
+	if err := s.Fill(next); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Render(); err != nil {
+		t.Fatal(err)
+	}
+	if got := opened[1].lastRendered(); !reflect.DeepEqual(got, next) {
+		t.Errorf("reopened strip shows %x after a render, want %x", got, next)
+	}
+}
+
+func TestKeepsFramesDrawnWhileDown(t *testing.T) {
+	o := &opener{}
+	s, err := New(o.open(2), 2, Threshold(1), Backoff(time.Millisecond, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Deinit()
+
+	o.setFail(true)
+	o.opened()[0].setFailing(true)
+	s.Render()
+	waitFor(t, "a restart to fail", func() bool {
+		return s.Stats().RestartFailures > 0
+	})
+
+	frame := []int{0x123456, 0x654321}
+	if err := s.Fill(frame); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Render(); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Stats().Down {
+		t.Fatal("strip should still be down")
+	}
+	if got := s.Rendered(); !reflect.DeepEqual(got, frame) {
+		t.Errorf("Rendered() = %x while the strip is down, want %x", got, frame)
+	}
+
+	o.setFail(false)
+	waitFor(t, "the strip to be reopened", func() bool {
+		return s.Stats().Restarts == 1
+	})
+	if got := o.opened()[1].lastRendered(); !reflect.DeepEqual(got, frame) {
+		t.Errorf("reopened strip shows %x, want the frame rendered while it was down %x", got, frame)
+	}
+}
+
+func TestDeinitStopsRestarting(t *testing.T) {
+	o := &opener{}
+	s, err := New(o.open(1), 1, Threshold(1), Backoff(time.Millisecond, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.setFail(true)
+	o.opened()[0].setFailing(true)
+	s.Render()
+	s.Deinit()
+
+	o.setFail(false)
+	time.Sleep(20 * time.Millisecond)
+	for i, strip := range o.opened() {
+		if !strip.isClosed() {
+			t.Errorf("strip %v left open after Deinit", i)
+		}
+	}
+	if restarts := s.Stats().Restarts; restarts != 0 {
+		t.Errorf("strip restarted %v times after Deinit, want 0", restarts)
+	}
+}
+
+func TestStatsJSON(t *testing.T) {
+	data, err := json.Marshal(Stats{Failures: 2, LastError: fmt.Errorf("DMA error")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"failures":2`) || !strings.Contains(string(data), `"lastError":"DMA error"`) {
+		t.Errorf("unexpected JSON: %s", data)
+	}
+	data, err = json.Marshal(Stats{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"lastError":""`) {
+		t.Errorf("unexpected JSON without an error: %s", data)
+	}
+}
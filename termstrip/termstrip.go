@@ -0,0 +1,159 @@
+//Package termstrip draws an LED strip in a terminal using 24-bit ANSI color escape codes.
+//Every LED is shown as a colored block, and each Render redraws the strip in place, so a full
+//forecast cycle can be watched over SSH without the physical map.
+//The terminal has to support truecolor, which most modern terminal emulators do.
+package termstrip
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"led-map/ledcolor"
+	"sync"
+)
+
+const (
+	defaultWidth = 25
+	//moves the cursor to the start of the line n lines up
+	cursorUp = "\x1b[%dF"
+	//sets the background color to an RGB value
+	background = "\x1b[48;2;%d;%d;%dm"
+	reset      = "\x1b[0m"
+)
+
+//TermStrip represents a string of LEDs drawn in a terminal.
+type TermStrip struct {
+	mu       sync.Mutex
+	out      io.Writer
+	pending  []int
+	autoFill bool
+	width    int
+	labels   bool
+	drawn    int //number of lines drawn by the last render, so the next one can overwrite them
+}
+
+//Function used to set options
+type option func(*TermStrip)
+
+//New returns a TermStrip with the supplied number of LEDs that draws to out, which is usually os.Stdout.
+//AutoFill behaves the same way it does for ledstrip.LedStrip.
+//Example:
+//	strip := termstrip.New(os.Stdout, 100, false) //100 LEDs, has to be manually rendered
+func New(out io.Writer, ledCount int, autoFill bool, opts ...option) *TermStrip {
+	t := &TermStrip{
+		out:      out,
+		pending:  make([]int, ledCount),
+		autoFill: autoFill,
+		width:    defaultWidth,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+//Width provides an option for setting how many LEDs are drawn on each line of the terminal.
+func Width(width int) option {
+	return func(t *TermStrip) {
+		if width > 0 {
+			t.width = width
+		}
+	}
+}
+
+//Labels provides an option for printing the index of the first LED at the start of every line.
+func Labels(labels bool) option {
+	return func(t *TermStrip) {
+		t.labels = labels
+	}
+}
+
+//FillSingle applies a color to all LEDs on the strip.
+func (t *TermStrip) FillSingle(color int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.pending {
+		t.pending[i] = color
+	}
+	if t.autoFill {
+		return t.render()
+	}
+	return nil
+}
+
+//Fill applies an array of colors to all LEDs on the strip. The array of colors must be the same length as the strip.
+func (t *TermStrip) Fill(colors []int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(colors) != len(t.pending) {
+		return fmt.Errorf("mismatch between number of colors and number of LEDs. colors = %v, LEDs = %v", len(colors), len(t.pending))
+	}
+	copy(t.pending, colors)
+	if t.autoFill {
+		return t.render()
+	}
+	return nil
+}
+
+//Set sets a single LED's color.
+func (t *TermStrip) Set(index int, color int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if index >= len(t.pending) || index < 0 {
+		return fmt.Errorf("index is out of bounds")
+	}
+	t.pending[index] = color
+	if t.autoFill {
+		return t.render()
+	}
+	return nil
+}
+
+//Render redraws the strip in the terminal, replacing the previous drawing.
+//If autoFill is true, there's no reason to use this.
+func (t *TermStrip) Render() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.render()
+}
+
+//render writes the whole strip in one go, so the terminal never shows half a frame. The caller must hold the lock.
+func (t *TermStrip) render() error {
+	w := bufio.NewWriter(t.out)
+	if t.drawn > 0 {
+		fmt.Fprintf(w, cursorUp, t.drawn)
+	}
+	lines := 0
+	for start := 0; start < len(t.pending); start += t.width {
+		end := start + t.width
+		if end > len(t.pending) {
+			end = len(t.pending)
+		}
+		if t.labels {
+			fmt.Fprintf(w, "%4d ", start)
+		}
+		for _, color := range t.pending[start:end] {
+			c := ledcolor.FromGrb(color)
+			fmt.Fprintf(w, background+"  "+reset, c.R, c.G, c.B)
+		}
+		fmt.Fprint(w, "\n")
+		lines++
+	}
+	err := w.Flush()
+	if err != nil {
+		return err
+	}
+	t.drawn = lines
+	return nil
+}
+
+//Deinit turns every LED off and leaves the cursor below the strip.
+func (t *TermStrip) Deinit() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.pending {
+		t.pending[i] = 0
+	}
+	t.render()
+	t.drawn = 0
+}
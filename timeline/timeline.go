@@ -0,0 +1,175 @@
+//Package timeline models what the map shows over time. A Timeline is a list of keyframes, one for every forecast
+//instant, each of them held for a while and followed by a transition that fades to the next one.
+//Colors are packed the way utilities.HsvToGrb packs them, with one color per LED.
+package timeline
+
+import (
+	"fmt"
+	"time"
+)
+
+//Frame is the color of every LED at one moment of a timeline.
+type Frame struct {
+	Colors []int         `json:"colors"`
+	At     time.Duration `json:"at"` //time since the start of the timeline
+}
+
+//Len returns the number of LEDs in the frame.
+func (f Frame) Len() int {
+	return len(f.Colors)
+}
+
+//Validate checks that the frame has a color for each of ledCount LEDs, and a time that isn't negative.
+func (f Frame) Validate(ledCount int) error {
+	if len(f.Colors) != ledCount {
+		return fmt.Errorf("mismatch between number of colors and number of LEDs. colors = %v, LEDs = %v", len(f.Colors), ledCount)
+	}
+	if f.At < 0 {
+		return fmt.Errorf("frame time can't be negative, got: %v", f.At)
+	}
+	return nil
+}
+
+//Keyframe is the map at one forecast instant. It is held before the transition after it starts.
+type Keyframe struct {
+	Colors []int         `json:"colors"`
+	Hold   time.Duration `json:"hold"`
+}
+
+//Len returns the number of LEDs in the keyframe.
+func (k Keyframe) Len() int {
+	return len(k.Colors)
+}
+
+//Validate checks that the keyframe has a color for each of ledCount LEDs, and a hold that isn't negative.
+func (k Keyframe) Validate(ledCount int) error {
+	if len(k.Colors) != ledCount {
+		return fmt.Errorf("mismatch between number of colors and number of LEDs. colors = %v, LEDs = %v", len(k.Colors), ledCount)
+	}
+	if k.Hold < 0 {
+		return fmt.Errorf("hold can't be negative, got: %v", k.Hold)
+	}
+	return nil
+}
+
+//Transition is the fade from one keyframe to the next, as the frames between them. Each of them is shown for one step.
+type Transition [][]int
+
+//Len returns the number of steps in the transition.
+func (t Transition) Len() int {
+	return len(t)
+}
+
+//Duration returns how long the transition takes when each step is shown for stepTime.
+func (t Transition) Duration(stepTime time.Duration) time.Duration {
+	return time.Duration(len(t)) * stepTime
+}
+
+//Validate checks that every step has a color for each of ledCount LEDs.
+func (t Transition) Validate(ledCount int) error {
+	for i, step := range t {
+		if len(step) != ledCount {
+			return fmt.Errorf("step %v: mismatch between number of colors and number of LEDs. colors = %v, LEDs = %v", i, len(step), ledCount)
+		}
+	}
+	return nil
+}
+
+//Timeline is a list of keyframes and the transitions between them.
+//Transitions[i] is shown after Keyframes[i]. Keyframes at the end of the list can be left without a transition.
+type Timeline struct {
+	Keyframes   []Keyframe    `json:"keyframes"`
+	Transitions []Transition  `json:"transitions"`
+	StepTime    time.Duration `json:"stepTime"` //how long each step of a transition is shown
+}
+
+//Pace sets how long every keyframe is held, the first one for initialHold and the others for hold,
+//and how long each transition step is shown.
+//Example:
+//	forecast, _ := templed.GetTimeline(temps, 40)
+//	forecast.Pace(10*time.Second, 3*time.Second, time.Second/20)
+func (t *Timeline) Pace(initialHold time.Duration, hold time.Duration, stepTime time.Duration) {
+	for i := range t.Keyframes {
+		t.Keyframes[i].Hold = hold
+		if i == 0 {
+			t.Keyframes[i].Hold = initialHold
+		}
+	}
+	t.StepTime = stepTime
+}
+
+//Transition returns the transition shown after keyframe i, which is empty if there is none.
+func (t Timeline) Transition(i int) Transition {
+	if i < 0 || i >= len(t.Transitions) {
+		return Transition{}
+	}
+	return t.Transitions[i]
+}
+
+//LedCount returns the number of LEDs in each frame.
+func (t Timeline) LedCount() int {
+	if len(t.Keyframes) == 0 {
+		return 0
+	}
+	return t.Keyframes[0].Len()
+}
+
+//Len returns the number of frames in the timeline, counting every keyframe and every transition step.
+func (t Timeline) Len() int {
+	length := len(t.Keyframes)
+	for i := range t.Keyframes {
+		length += t.Transition(i).Len()
+	}
+	return length
+}
+
+//Duration returns how long the timeline takes to show once.
+func (t Timeline) Duration() time.Duration {
+	var duration time.Duration
+	for i, keyframe := range t.Keyframes {
+		duration += keyframe.Hold + t.Transition(i).Duration(t.StepTime)
+	}
+	return duration
+}
+
+//Frames returns every frame of the timeline, in order, each with the time it is shown at.
+//The colors are shared with the timeline, not copied.
+func (t Timeline) Frames() []Frame {
+	frames := make([]Frame, 0, t.Len())
+	var at time.Duration
+	for i, keyframe := range t.Keyframes {
+		frames = append(frames, Frame{Colors: keyframe.Colors, At: at})
+		at += keyframe.Hold
+		for _, step := range t.Transition(i) {
+			frames = append(frames, Frame{Colors: step, At: at})
+			at += t.StepTime
+		}
+	}
+	return frames
+}
+
+//Validate checks that the timeline has at least one keyframe, that every frame has the same number of LEDs,
+//and that no time is negative.
+func (t Timeline) Validate() error {
+	if len(t.Keyframes) == 0 {
+		return fmt.Errorf("a timeline needs at least one keyframe")
+	}
+	if len(t.Transitions) > len(t.Keyframes) {
+		return fmt.Errorf("every transition must follow a keyframe, got %v keyframes and %v transitions", len(t.Keyframes), len(t.Transitions))
+	}
+	if t.StepTime < 0 {
+		return fmt.Errorf("step time can't be negative, got: %v", t.StepTime)
+	}
+	ledCount := t.LedCount()
+	for i, keyframe := range t.Keyframes {
+		err := keyframe.Validate(ledCount)
+		if err != nil {
+			return fmt.Errorf("keyframe %v: %v", i, err)
+		}
+		err = t.Transition(i).Validate(ledCount)
+		if err != nil {
+			return fmt.Errorf("transition %v: %v", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+//Package virtualstrip provides an in-memory LED strip that never touches real hardware.
+//It implements ledmap.ColorFiller and records every frame it renders, along with the time it
+//was rendered, so that maps and controllers can be exercised on machines without a Raspberry Pi.
+package virtualstrip
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//Frame is a snapshot of the strip taken when it was rendered.
+type Frame struct {
+	Time   time.Time
+	Colors []int
+}
+
+//VirtualStrip represents a string of LEDs that only exists in memory.
+//It is safe to inspect a VirtualStrip from one goroutine while another one is filling it.
+type VirtualStrip struct {
+	mu       sync.Mutex
+	pending  []int
+	rendered []int
+	frames   []Frame
+	autoFill bool
+	now      func() time.Time
+}
+
+//Function used to set options
+type option func(*VirtualStrip)
+
+//New returns a VirtualStrip with the supplied number of LEDs, all of them off.
+//AutoFill behaves the same way it does for ledstrip.LedStrip: when it is true, every change
+//is rendered immediately, otherwise changes stay pending until Render is called.
+//Example:
+//	strip := virtualstrip.New(100, false) //100 LEDs, has to be manually rendered
+func New(ledCount int, autoFill bool, opts ...option) *VirtualStrip {
+	v := &VirtualStrip{
+		pending:  make([]int, ledCount),
+		rendered: make([]int, ledCount),
+		frames:   make([]Frame, 0),
+		autoFill: autoFill,
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+//Clock provides an option for replacing the function used to timestamp frames.
+//This is useful when a test needs predictable timestamps.
+func Clock(now func() time.Time) option {
+	return func(v *VirtualStrip) {
+		v.now = now
+	}
+}
+
+//FillSingle applies a color to all LEDs on the strip.
+func (v *VirtualStrip) FillSingle(color int) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i := range v.pending {
+		v.pending[i] = color
+	}
+	if v.autoFill {
+		v.render()
+	}
+	return nil
+}
+
+//Fill applies an array of colors to all LEDs on the strip. The array of colors must be the same length as the strip.
+func (v *VirtualStrip) Fill(colors []int) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(colors) != len(v.pending) {
+		return fmt.Errorf("mismatch between number of colors and number of LEDs. colors = %v, LEDs = %v", len(colors), len(v.pending))
+	}
+	copy(v.pending, colors)
+	if v.autoFill {
+		v.render()
+	}
+	return nil
+}
+
+//Set sets a single LED's color.
+func (v *VirtualStrip) Set(index int, color int) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if index >= len(v.pending) || index < 0 {
+		return fmt.Errorf("index is out of bounds")
+	}
+	v.pending[index] = color
+	if v.autoFill {
+		v.render()
+	}
+	return nil
+}
+
+//Render records all pending color changes as a new frame.
+//If autoFill is true, there's no reason to use this.
+func (v *VirtualStrip) Render() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.render()
+	return nil
+}
+
+//render copies the pending colors into a new frame. The caller must hold the lock.
+func (v *VirtualStrip) render() {
+	copy(v.rendered, v.pending)
+	v.frames = append(v.frames, Frame{
+		Time:   v.now(),
+		Colors: copyColors(v.rendered),
+	})
+}
+
+//Len returns the number of LEDs on the strip.
+func (v *VirtualStrip) Len() int {
+	return len(v.pending)
+}
+
+//Pending returns a copy of the colors that have been set but not necessarily rendered.
+func (v *VirtualStrip) Pending() []int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return copyColors(v.pending)
+}
+
+//Rendered returns a copy of the colors that were last rendered, which is what a real strip would be showing.
+func (v *VirtualStrip) Rendered() []int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return copyColors(v.rendered)
+}
+
+//Dirty reports whether there are changes that have not been rendered yet.
+func (v *VirtualStrip) Dirty() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i := range v.pending {
+		if v.pending[i] != v.rendered[i] {
+			return true
+		}
+	}
+	return false
+}
+
+//Frames returns every frame rendered so far, oldest first.
+func (v *VirtualStrip) Frames() []Frame {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	frames := make([]Frame, len(v.frames))
+	for i, frame := range v.frames {
+		frames[i] = Frame{Time: frame.Time, Colors: copyColors(frame.Colors)}
+	}
+	return frames
+}
+
+//FrameCount returns the number of frames rendered so far.
+func (v *VirtualStrip) FrameCount() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.frames)
+}
+
+//LastFrame returns the most recently rendered frame. The boolean is false if nothing has been rendered yet.
+func (v *VirtualStrip) LastFrame() (Frame, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.frames) == 0 {
+		return Frame{}, false
+	}
+	frame := v.frames[len(v.frames)-1]
+	return Frame{Time: frame.Time, Colors: copyColors(frame.Colors)}, true
+}
+
+//Reset forgets the recorded frame history. The current pending and rendered colors are kept.
+func (v *VirtualStrip) Reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.frames = make([]Frame, 0)
+}
+
+//Deinit exists so a VirtualStrip can stand in for ledstrip.LedStrip. It turns every LED off without recording a frame.
+func (v *VirtualStrip) Deinit() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i := range v.pending {
+		v.pending[i] = 0
+		v.rendered[i] = 0
+	}
+}
+
+func copyColors(colors []int) []int {
+	c := make([]int, len(colors))
+	copy(c, colors)
+	return c
+}
@@ -0,0 +1,340 @@
+//Package wled sends LED frames to WLED nodes, or anything else that speaks DDP, over UDP.
+//A WLED is a ColorFiller, so LedMap can drive a remote map over Wi-Fi the same way it drives a local strip.
+//
+//WLED shows realtime frames instead of its own effects until it stops receiving them for a while, then returns
+//to its default. Once the first frame has been rendered, the WLED keeps resending the last frame while the map is
+//holding still, so the node doesn't give up on it in the middle of a long pause. Nothing is sent before the first
+//render or after Deinit, so the node shows its own effects until the map starts, and returns to them once it stops.
+package wled
+
+import (
+	"fmt"
+	"led-map/ledcolor"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//Protocol is the UDP protocol frames are sent with.
+type Protocol int
+
+const (
+	//DDP is the Distributed Display Protocol. It has no size limit, and is understood by more than just WLED.
+	DDP Protocol = iota
+	//DRGB is WLED's realtime protocol that sends every LED in one packet. It is limited to 490 LEDs.
+	DRGB
+	//DNRGB is WLED's realtime protocol with a start index, so longer strips are split over several packets.
+	DNRGB
+)
+
+const (
+	//DDPPort is the UDP port DDP receivers listen on.
+	DDPPort = 4048
+	//RealtimePort is the UDP port WLED listens on for its realtime protocols.
+	RealtimePort = 21324
+	//Forever is a timeout that keeps WLED in realtime mode until it is rebooted or told otherwise.
+	Forever = 255 * time.Second
+)
+
+//Packet limits, chosen so every packet fits in a single ethernet frame
+const (
+	ddpHeaderLength   = 10
+	ddpMaxData        = 1440 //480 RGB pixels
+	drgbMaxLeds       = 490
+	dnrgbMaxLeds      = 489
+	realtimeDRGB      = 2
+	realtimeDNRGB     = 4
+	ddpVersion1       = 0x40
+	ddpPush           = 0x01
+	ddpTypeRGB8       = 0x0b
+	ddpDefaultOutput  = 0x01
+	defaultTimeout    = 2 * time.Second
+	defaultKeepAlive  = time.Second
+	minKeepAlive      = 10 * time.Millisecond
+	ddpSequenceLength = 15
+)
+
+//WLED is a ColorFiller that sends frames to a WLED node.
+type WLED struct {
+	mu        sync.Mutex
+	conn      net.Conn
+	protocol  Protocol
+	pending   []int
+	rendered  []int
+	autoFill  bool
+	timeout   time.Duration
+	keepAlive time.Duration
+	sequence  byte
+	lastSent  time.Time
+	keeping   bool //whether keepAliveLoop has been started
+	stop      chan struct{}
+}
+
+//Function used to set options
+type option func(*WLED)
+
+//New returns a WLED that sends the colors of ledCount LEDs to the node at host.
+//Host can include a port, otherwise the protocol's standard port is used.
+//AutoFill behaves the same way it does for ledstrip.LedStrip.
+//Example:
+//	node, err := wled.New("wled-livingroom.local", 100, false, wled.Using(wled.DNRGB), wled.Timeout(5*time.Second))
+func New(host string, ledCount int, autoFill bool, opts ...option) (*WLED, error) {
+	w := &WLED{
+		protocol:  DDP,
+		pending:   make([]int, ledCount),
+		rendered:  make([]int, ledCount),
+		autoFill:  autoFill,
+		timeout:   defaultTimeout,
+		keepAlive: defaultKeepAlive,
+		stop:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	err := w.validate()
+	if err != nil {
+		return &WLED{}, err
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		port := DDPPort
+		if w.protocol != DDP {
+			port = RealtimePort
+		}
+		host = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	w.conn, err = net.Dial("udp", host)
+	if err != nil {
+		return &WLED{}, err
+	}
+	return w, nil
+}
+
+//Using provides an option for choosing the protocol. DDP is the default.
+func Using(protocol Protocol) option {
+	return func(w *WLED) {
+		w.protocol = protocol
+	}
+}
+
+//Timeout provides an option for setting how long WLED waits without frames before returning to its default effect.
+//It is rounded to whole seconds, from 1 second up to Forever. DDP has no timeout field, so with DDP the node's own setting is used.
+func Timeout(timeout time.Duration) option {
+	return func(w *WLED) {
+		w.timeout = timeout
+	}
+}
+
+//KeepAlive provides an option for setting how often the last frame is resent while nothing is rendered.
+//Resending starts after the first render. It should be shorter than the timeout, and can't be shorter than 10ms.
+//0 turns resending off.
+func KeepAlive(interval time.Duration) option {
+	return func(w *WLED) {
+		w.keepAlive = interval
+	}
+}
+
+func (w *WLED) validate() error {
+	switch w.protocol {
+	case DDP, DNRGB:
+	case DRGB:
+		if len(w.pending) > drgbMaxLeds {
+			return fmt.Errorf("DRGB is limited to %v LEDs, got: %v, use DNRGB or DDP instead", drgbMaxLeds, len(w.pending))
+		}
+	default:
+		return fmt.Errorf("unknown WLED protocol %v", w.protocol)
+	}
+	if w.timeout < time.Second || w.timeout > Forever {
+		return fmt.Errorf("timeout must be between 1s and %v, got: %v", Forever, w.timeout)
+	}
+	if w.keepAlive != 0 && w.keepAlive < minKeepAlive {
+		return fmt.Errorf("keep alive interval must be 0 or at least %v, got: %v", minKeepAlive, w.keepAlive)
+	}
+	return nil
+}
+
+//FillSingle applies a color to all LEDs.
+func (w *WLED) FillSingle(color int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i := range w.pending {
+		w.pending[i] = color
+	}
+	if w.autoFill {
+		return w.render()
+	}
+	return nil
+}
+
+//Fill applies an array of colors to all LEDs. The array of colors must be the same length as the number of LEDs.
+func (w *WLED) Fill(colors []int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(colors) != len(w.pending) {
+		return fmt.Errorf("mismatch between number of colors and number of LEDs. colors = %v, LEDs = %v", len(colors), len(w.pending))
+	}
+	copy(w.pending, colors)
+	if w.autoFill {
+		return w.render()
+	}
+	return nil
+}
+
+//Set sets a single LED's color.
+func (w *WLED) Set(index int, color int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if index >= len(w.pending) || index < 0 {
+		return fmt.Errorf("index is out of bounds")
+	}
+	w.pending[index] = color
+	if w.autoFill {
+		return w.render()
+	}
+	return nil
+}
+
+//Render sends the frame to the node.
+//If autoFill is true, there's no reason to use this.
+func (w *WLED) Render() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.render()
+}
+
+//render takes a snapshot of the pending colors and sends it. The first frame that is sent starts resending.
+//The caller must hold the lock.
+func (w *WLED) render() error {
+	copy(w.rendered, w.pending)
+	err := w.send()
+	if err != nil {
+		return err
+	}
+	if w.keepAlive > 0 && !w.keeping {
+		w.keeping = true
+		go w.keepAliveLoop()
+	}
+	return nil
+}
+
+//send sends the last rendered frame. The caller must hold the lock.
+func (w *WLED) send() error {
+	var packets [][]byte
+	switch w.protocol {
+	case DRGB:
+		packets = [][]byte{w.realtimePacket(realtimeDRGB, 0, len(w.rendered))}
+	case DNRGB:
+		for start := 0; start < len(w.rendered) || start == 0; start += dnrgbMaxLeds {
+			packets = append(packets, w.realtimePacket(realtimeDNRGB, start, dnrgbMaxLeds))
+		}
+	default:
+		packets = w.ddpPackets()
+	}
+	for _, packet := range packets {
+		_, err := w.conn.Write(packet)
+		if err != nil {
+			return err
+		}
+	}
+	w.lastSent = time.Now()
+	return nil
+}
+
+//realtimePacket builds a WLED realtime packet carrying up to count LEDs, starting at start.
+//DRGB packets have no start index, so start must be 0 for them.
+func (w *WLED) realtimePacket(protocol byte, start int, count int) []byte {
+	end := start + count
+	if end > len(w.rendered) {
+		end = len(w.rendered)
+	}
+	header := []byte{protocol, w.timeoutSeconds()}
+	if protocol == realtimeDNRGB {
+		header = append(header, byte(start>>8), byte(start))
+	}
+	packet := make([]byte, len(header), len(header)+(end-start)*3)
+	copy(packet, header)
+	for _, color := range w.rendered[start:end] {
+		packet = append(packet, ledcolor.RGB.Bytes(ledcolor.FromGrb(color))...)
+	}
+	return packet
+}
+
+//ddpPackets splits the last rendered frame into DDP packets. Only the last one is flagged to push the frame to the LEDs.
+func (w *WLED) ddpPackets() [][]byte {
+	data := make([]byte, 0, len(w.rendered)*3)
+	for _, color := range w.rendered {
+		data = append(data, ledcolor.RGB.Bytes(ledcolor.FromGrb(color))...)
+	}
+	//Sequence numbers go from 1 to 15, 0 means the receiver shouldn't check them
+	w.sequence = w.sequence%ddpSequenceLength + 1
+
+	packets := make([][]byte, 0)
+	for offset := 0; offset < len(data) || offset == 0; offset += ddpMaxData {
+		end := offset + ddpMaxData
+		if end > len(data) {
+			end = len(data)
+		}
+		flags := byte(ddpVersion1)
+		if end == len(data) {
+			flags |= ddpPush
+		}
+		packet := make([]byte, ddpHeaderLength+end-offset)
+		packet[0] = flags
+		packet[1] = w.sequence
+		packet[2] = ddpTypeRGB8
+		packet[3] = ddpDefaultOutput
+		packet[4] = byte(offset >> 24)
+		packet[5] = byte(offset >> 16)
+		packet[6] = byte(offset >> 8)
+		packet[7] = byte(offset)
+		packet[8] = byte((end - offset) >> 8)
+		packet[9] = byte(end - offset)
+		copy(packet[ddpHeaderLength:], data[offset:end])
+		packets = append(packets, packet)
+	}
+	return packets
+}
+
+//timeoutSeconds returns the timeout in the form WLED's realtime protocols expect it.
+func (w *WLED) timeoutSeconds() byte {
+	return byte((w.timeout + time.Second/2) / time.Second)
+}
+
+//keepAliveLoop resends the last frame whenever nothing has been sent for a while.
+//Send errors are dropped, the next frame rendered by the map will report them.
+func (w *WLED) keepAliveLoop() {
+	ticker := time.NewTicker(w.keepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case now := <-ticker.C:
+			w.mu.Lock()
+			select {
+			case <-w.stop:
+				//Deinit ran while waiting for the lock
+				w.mu.Unlock()
+				return
+			default:
+			}
+			if now.Sub(w.lastSent) >= w.keepAlive {
+				w.send()
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+//Deinit stops resending frames and closes the connection. The node returns to its default effect once its timeout runs out.
+func (w *WLED) Deinit() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	select {
+	case <-w.stop:
+		return
+	default:
+	}
+	close(w.stop)
+	w.conn.Close()
+}
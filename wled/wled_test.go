@@ -0,0 +1,218 @@
+package wled
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+//listen opens a UDP socket on the loopback interface for a WLED to send to.
+func listen(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+//receive reads the next packet sent to conn.
+func receive(t *testing.T, conn *net.UDPConn) []byte {
+	t.Helper()
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf[:n]
+}
+
+//expectNothing fails the test if a packet is sent to conn within wait.
+func expectNothing(t *testing.T, conn *net.UDPConn, wait time.Duration) {
+	t.Helper()
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(wait))
+	n, err := conn.Read(buf)
+	if err == nil {
+		t.Fatalf("unexpected packet: %x", buf[:n])
+	}
+}
+
+//frame returns ledCount colors, each of them different.
+func frame(ledCount int) []int {
+	colors := make([]int, ledCount)
+	for i := range colors {
+		colors[i] = 0x010203 * (i + 1)
+	}
+	return colors
+}
+
+//rgb returns the colors of frame as they are sent, three bytes per LED in RGB order.
+func rgb(colors []int) []byte {
+	data := make([]byte, 0, len(colors)*3)
+	for _, color := range colors {
+		//Colors are packed GRB
+		data = append(data, byte(color>>8), byte(color>>16), byte(color))
+	}
+	return data
+}
+
+//open returns a WLED of ledCount LEDs that sends to conn, with resending turned off.
+func open(t *testing.T, conn *net.UDPConn, ledCount int, opts ...option) *WLED {
+	t.Helper()
+	opts = append([]option{KeepAlive(0)}, opts...)
+	w, err := New(conn.LocalAddr().String(), ledCount, false, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(w.Deinit)
+	return w
+}
+
+func TestDDP(t *testing.T) {
+	conn := listen(t)
+	w := open(t, conn, 500)
+	colors := frame(500)
+	err := w.Fill(colors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := rgb(colors)
+	first := receive(t, conn)
+	second := receive(t, conn)
+	wantFirst := []byte{ddpVersion1, 1, ddpTypeRGB8, ddpDefaultOutput, 0, 0, 0, 0, 0x05, 0xa0}
+	if !bytes.Equal(first[:ddpHeaderLength], wantFirst) {
+		t.Errorf("first header = %x, want %x", first[:ddpHeaderLength], wantFirst)
+	}
+	if !bytes.Equal(first[ddpHeaderLength:], data[:ddpMaxData]) {
+		t.Error("first packet doesn't carry the first 480 LEDs")
+	}
+	//Only the last packet pushes, and its offset is in bytes
+	wantSecond := []byte{ddpVersion1 | ddpPush, 1, ddpTypeRGB8, ddpDefaultOutput, 0, 0, 0x05, 0xa0, 0, 60}
+	if !bytes.Equal(second[:ddpHeaderLength], wantSecond) {
+		t.Errorf("second header = %x, want %x", second[:ddpHeaderLength], wantSecond)
+	}
+	if !bytes.Equal(second[ddpHeaderLength:], data[ddpMaxData:]) {
+		t.Error("second packet doesn't carry the last 20 LEDs")
+	}
+}
+
+func TestDDPSequenceWraps(t *testing.T) {
+	conn := listen(t)
+	w := open(t, conn, 1)
+	for render := 1; render <= ddpSequenceLength+2; render++ {
+		err := w.Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := byte((render-1)%ddpSequenceLength + 1)
+		if got := receive(t, conn)[1]; got != want {
+			t.Errorf("render %v has sequence number %v, want %v", render, got, want)
+		}
+	}
+}
+
+func TestDRGB(t *testing.T) {
+	conn := listen(t)
+	w := open(t, conn, 3, Using(DRGB), Timeout(5*time.Second))
+	colors := frame(3)
+	err := w.Fill(colors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte{realtimeDRGB, 5}, rgb(colors)...)
+	if got := receive(t, conn); !bytes.Equal(got, want) {
+		t.Errorf("packet = %x, want %x", got, want)
+	}
+}
+
+func TestDRGBLimit(t *testing.T) {
+	conn := listen(t)
+	_, err := New(conn.LocalAddr().String(), drgbMaxLeds+1, false, Using(DRGB))
+	if err == nil {
+		t.Error("DRGB accepted more LEDs than fit in a packet")
+	}
+}
+
+func TestDNRGB(t *testing.T) {
+	conn := listen(t)
+	w := open(t, conn, 500, Using(DNRGB), Timeout(Forever))
+	colors := frame(500)
+	err := w.Fill(colors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := rgb(colors)
+	want := append([]byte{realtimeDNRGB, 255, 0, 0}, data[:dnrgbMaxLeds*3]...)
+	if got := receive(t, conn); !bytes.Equal(got, want) {
+		t.Errorf("first packet = %x, want %x", got, want)
+	}
+	//The second packet starts at LED 489
+	want = append([]byte{realtimeDNRGB, 255, 0x01, 0xe9}, data[dnrgbMaxLeds*3:]...)
+	if got := receive(t, conn); !bytes.Equal(got, want) {
+		t.Errorf("second packet = %x, want %x", got, want)
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	conn := listen(t)
+	w, err := New(conn.LocalAddr().String(), 1, false, KeepAlive(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectNothing(t, conn, 60*time.Millisecond)
+
+	err = w.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := receive(t, conn)
+	resent := receive(t, conn)
+	if !bytes.Equal(first[ddpHeaderLength:], resent[ddpHeaderLength:]) {
+		t.Errorf("resent %x, want the last frame %x", resent, first)
+	}
+
+	w.Deinit()
+	//Drain anything sent before Deinit returned
+	conn.SetReadDeadline(time.Now().Add(30 * time.Millisecond))
+	for {
+		if _, err := conn.Read(make([]byte, 2048)); err != nil {
+			break
+		}
+	}
+	expectNothing(t, conn, 60*time.Millisecond)
+}
+
+func TestKeepAliveValidation(t *testing.T) {
+	conn := listen(t)
+	for _, interval := range []time.Duration{-time.Second, time.Nanosecond, minKeepAlive - 1} {
+		_, err := New(conn.LocalAddr().String(), 1, false, KeepAlive(interval))
+		if err == nil {
+			t.Errorf("keep alive interval %v was accepted", interval)
+		}
+	}
+	for _, interval := range []time.Duration{0, minKeepAlive} {
+		w, err := New(conn.LocalAddr().String(), 1, false, KeepAlive(interval))
+		if err != nil {
+			t.Errorf("keep alive interval %v was rejected: %v", interval, err)
+			continue
+		}
+		w.Deinit()
+	}
+}